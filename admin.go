@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// admin routes are disabled unless ADMIN_API_KEY is set
+var adminApiKey = envString("ADMIN_API_KEY", "")
+
+const scanBatchSize = 500
+
+const purgeTimeout = time.Minute
+
+func requestApiKey(r *http.Request) string {
+	key := r.Header.Get("X-API-Key")
+	if len(key) < 1 {
+		key = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return strings.TrimSpace(key)
+}
+
+// requireApiKey guards operator endpoints with the X-API-Key or Bearer token header
+func requireApiKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(adminApiKey) < 1 {
+			writeError(w, http.StatusForbidden, "admin endpoints are disabled")
+			return
+		}
+		key := requestApiKey(r)
+		if subtle.ConstantTimeCompare([]byte(key), []byte(adminApiKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid api key")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// escapeKeyPattern escapes Redis glob characters so a prefix is matched literally
+func escapeKeyPattern(prefix string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+	return replacer.Replace(prefix)
+}
+
+// purgeCache deletes every key under prefix in batches, as a scan may return many keys
+func purgeCache(parent context.Context, prefix string) (int64, error) {
+	// a full scan may take many round trips, so it gets a longer budget than single operations
+	ctx, cancel := context.WithTimeout(parent, purgeTimeout)
+	defer cancel()
+	store := cacheStore()
+	var removed int64
+	var deleteErr error
+	keys := []string{}
+	deleteKeys := func() bool {
+		num, err := store.Delete(ctx, keys...)
+		removed += num
+		deleteErr = err
+		keys = []string{}
+		return err == nil
+	}
+	err := store.Scan(ctx, prefix, func(key string) bool {
+		keys = append(keys, key)
+		if len(keys) >= scanBatchSize {
+			return deleteKeys()
+		}
+		return true
+	})
+	if deleteErr != nil {
+		return removed, deleteErr
+	}
+	if err != nil {
+		return removed, err
+	}
+	if len(keys) > 0 && !deleteKeys() {
+		return removed, deleteErr
+	}
+	return removed, nil
+}
+
+func purgeCacheJson(w http.ResponseWriter, r *http.Request) {
+	removed, err := purgeCache(r.Context(), cachePrefix)
+	if err != nil {
+		requestLogger(r).Printf("cache purge failed prefix=%s removed=%d error=%q", cachePrefix, removed, err.Error())
+		writeError(w, http.StatusServiceUnavailable, "cache purge failed")
+		return
+	}
+	requestLogger(r).Printf("cache purged prefix=%s removed=%d", cachePrefix, removed)
+	writeJson(w, r, map[string]interface{}{"prefix": cachePrefix, "removed": removed})
+}
+
+type CachedEntry struct {
+	Key        string `json:"key"`
+	Endpoint   string `json:"endpoint"`
+	Uri        string `json:"uri"`
+	TtlSeconds int64  `json:"ttlSeconds"`
+}
+
+type CacheListing struct {
+	Entries   []CachedEntry `json:"entries"`
+	Truncated bool          `json:"truncated"`
+}
+
+const defaultCacheListLimit = 1000
+
+const maxCacheListLimit = 10000
+
+// parseCacheKey splits a cache key into the endpoint and the uri it holds,
+// blog keys carry the bare page path while other endpoints use endpointCacheKey
+func parseCacheKey(key string) (string, string) {
+	rest := strings.TrimPrefix(key, cachePrefix)
+	if strings.HasPrefix(rest, "~") {
+		parts := strings.SplitN(rest[1:], ":", 2)
+		if len(parts) == 2 {
+			return parts[0], parts[1]
+		}
+	}
+	return "blog", rest
+}
+
+// addCacheEntries looks up the remaining lifetime of a batch of keys in one call
+func addCacheEntries(ctx context.Context, entries []CachedEntry, keys []string) ([]CachedEntry, error) {
+	ttls, err := cacheStore().TTL(ctx, keys...)
+	if err != nil {
+		return entries, err
+	}
+	for i := 0; i < len(keys); i++ {
+		// keys that expired between the scan and the lookup are skipped
+		if ttls[i] == -2 {
+			continue
+		}
+		endpoint, uri := parseCacheKey(keys[i])
+		entries = append(entries, CachedEntry{Key: keys[i], Endpoint: endpoint, Uri: uri, TtlSeconds: int64(ttls[i].Seconds())})
+	}
+	return entries, nil
+}
+
+// listCache returns up to limit entries under prefix with their TTLs, scanning incrementally
+func listCache(parent context.Context, prefix string, limit int) (CacheListing, error) {
+	ctx, cancel := context.WithTimeout(parent, purgeTimeout)
+	defer cancel()
+	listing := CacheListing{Entries: []CachedEntry{}}
+	keys := []string{}
+	var err error
+	scanErr := cacheStore().Scan(ctx, prefix, func(key string) bool {
+		if len(listing.Entries)+len(keys) >= limit {
+			listing.Truncated = true
+			return false
+		}
+		keys = append(keys, key)
+		if len(keys) >= scanBatchSize {
+			if listing.Entries, err = addCacheEntries(ctx, listing.Entries, keys); err != nil {
+				return false
+			}
+			keys = []string{}
+		}
+		return true
+	})
+	if err != nil {
+		return listing, err
+	}
+	if scanErr != nil {
+		return listing, scanErr
+	}
+	if len(keys) > 0 {
+		listing.Entries, err = addCacheEntries(ctx, listing.Entries, keys)
+	}
+	return listing, err
+}
+
+func listCacheJson(w http.ResponseWriter, r *http.Request) {
+	limit := queryInt(r, "limit")
+	if limit < 1 {
+		limit = defaultCacheListLimit
+	}
+	if limit > maxCacheListLimit {
+		limit = maxCacheListLimit
+	}
+	listing, err := listCache(r.Context(), cachePrefix, limit)
+	if err != nil {
+		requestLogger(r).Printf("cache listing failed prefix=%s error=%q", cachePrefix, err.Error())
+		writeError(w, http.StatusServiceUnavailable, "cache listing failed")
+		return
+	}
+	writeJson(w, r, listing)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// readAltTexts lists the non-empty alt texts of the images in selection and counts the images
+// without an alt attribute. An empty alt marks a decorative image, so it is not counted as missing
+func readAltTexts(selection *goquery.Selection) ([]string, int) {
+	altTexts := []string{}
+	missing := 0
+	selection.Find("img").Each(func(_ int, s *goquery.Selection) {
+		alt, exists := s.Attr("alt")
+		if !exists {
+			missing++
+			return
+		}
+		if alt = removeSpaces(strings.TrimSpace(alt)); len(alt) > 0 {
+			altTexts = append(altTexts, alt)
+		}
+	})
+	return altTexts, missing
+}
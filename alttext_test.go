@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const altTextImages = `<html><body>
+<img src="/a.jpg" alt="A  harbour at dusk">
+<img src="/b.jpg" alt="">
+<img src="/c.jpg" alt="   ">
+<img src="/d.jpg">
+<figure><img src="/e.jpg"></figure>
+</body></html>`
+
+func TestImagesWithoutAltAreCounted(t *testing.T) {
+	altTexts, missing := readAltTexts(openHtml(t, altTextImages).Find("body"))
+	if missing != 2 {
+		t.Errorf("expected the 2 images without alt to be missing, decorative ones aside, got %d", missing)
+	}
+	if len(altTexts) != 1 || altTexts[0] != "A harbour at dusk" {
+		t.Errorf("expected only the non-empty alt text, got %q", altTexts)
+	}
+}
+
+func TestAltTextsAreListedOnRequest(t *testing.T) {
+	server := serveHtml(t, altTextImages)
+	with := getRoute(blogRoute(server.URL+"/", "bypass") + "?altText=true")
+	if !strings.Contains(with.Body.String(), `"imagesMissingAlt":2`) || !strings.Contains(with.Body.String(), `"altTexts":["A harbour at dusk"]`) {
+		t.Errorf("expected the count and the alt texts, got %s", with.Body.String())
+	}
+	without := getRoute(blogRoute(server.URL+"/", "bypass"))
+	if !strings.Contains(without.Body.String(), `"imagesMissingAlt":2`) || strings.Contains(without.Body.String(), `"altTexts"`) {
+		t.Errorf("expected the count without the alt texts, got %s", without.Body.String())
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// number of pages of a batch fetched concurrently
+var batchWorkers = envInt("BATCH_WORKERS", 4)
+
+// most urls accepted in one batch
+var batchMaxUrls = envInt("BATCH_MAX_URLS", 100)
+
+// splitBatchUrl separates an optional scheme from the host and path of a batch entry
+func splitBatchUrl(entry string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(entry), "://", 2)
+	if len(parts) == 2 {
+		return strings.ToLower(parts[0]), parts[1]
+	}
+	return "", parts[0]
+}
+
+type batchItem struct {
+	entry string
+	page  Page
+}
+
+// uniqueUrls drops repeated urls, keeping the first occurrence, so each is fetched once per batch
+func uniqueUrls(urls []string) []string {
+	unique := []string{}
+	for i := 0; i < len(urls); i++ {
+		entry := strings.TrimSpace(urls[i])
+		if len(entry) > 0 && !stringInList(unique, entry) {
+			unique = append(unique, entry)
+		}
+	}
+	return unique
+}
+
+// runBatch reads each url with a bounded pool of workers and calls emit with the url and its page
+// as each completes. emit is never called concurrently, and urls left once ctx is cancelled are skipped
+func runBatch(ctx context.Context, urls []string, opts FetchOptions, emit func(string, Page)) {
+	opts.Polite = true
+	// with no worker the pages would close at once and the urls never be handed over
+	workers := batchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan string)
+	pages := make(chan batchItem)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				scheme, path := splitBatchUrl(entry)
+				if !isValidScheme(effectiveScheme(scheme)) {
+					page := emptyPage()
+					page.Uri = entry
+					page.Error = "unsupported scheme: " + scheme
+					pages <- batchItem{entry, page}
+					continue
+				}
+				page, _, _ := readBlogPage(ctx, path, scheme, true, opts)
+				pages <- batchItem{entry, page}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for i := 0; i < len(urls); i++ {
+			select {
+			case jobs <- urls[i]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(pages)
+	}()
+	for item := range pages {
+		emit(item.entry, item.page)
+	}
+}
+
+type BatchResult struct {
+	Pages []Page `json:"pages"`
+}
+
+const idempotencyHeader = "Idempotency-Key"
+
+// results of a batch sent with an Idempotency-Key are replayed for this long
+var idempotencyWindowMinutes = envInt("IDEMPOTENCY_WINDOW_MINUTES", 5)
+
+const maxBatchBodyBytes = 1 << 20
+
+// idempotencyCacheKey binds the client key to the submitted urls, so reusing a key
+// for a different batch does not replay an unrelated result
+func idempotencyCacheKey(key string, urls []string) string {
+	return statsCacheKey("idempotency:" + key + ":" + contentHash(strings.Join(urls, "\n")))
+}
+
+// batchPage prepares a page read in a batch for its message: timings and headers are left out,
+// and the date range, required language and links cap apply as they do to single reads, after caching
+func batchPage(page Page, opts ExtractOptions) Page {
+	page.Timings = nil
+	page.Headers = nil
+	page.Articles = filterArticlesByDate(page.Articles, opts.Published)
+	page.requireLanguage(opts.RequireLang)
+	page.limitLinks(opts.MaxLinks)
+	return page
+}
+
+// readBatch fetches each distinct url once and returns the pages in the order of urls,
+// repeating the page for every position of a duplicated url. onPage, when set, is called
+// with each distinct page as it completes
+func readBatch(ctx context.Context, urls []string, opts FetchOptions, onPage func(Page)) BatchResult {
+	results := map[string]Page{}
+	runBatch(ctx, uniqueUrls(urls), opts, func(entry string, page Page) {
+		page = batchPage(page, opts.Extract)
+		results[entry] = page
+		if onPage != nil {
+			onPage(page)
+		}
+	})
+	pages := []Page{}
+	for i := 0; i < len(urls); i++ {
+		page, exists := results[strings.TrimSpace(urls[i])]
+		if !exists {
+			page = emptyPage()
+			page.Uri = urls[i]
+			page.Error = "not fetched"
+		}
+		pages = append(pages, page)
+	}
+	return BatchResult{Pages: pages}
+}
+
+// batchJson reads the pages of a {"urls": [...], "headers": {...}} body. With an Idempotency-Key header
+// a repeated submission within IDEMPOTENCY_WINDOW_MINUTES returns the stored result.
+// With ?format=ndjson each page is written on its own line as soon as it is read
+func batchJson(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "expected a JSON body with a urls list")
+		return
+	}
+	if len(req.Urls) > batchMaxUrls {
+		writeError(w, http.StatusBadRequest, "too many urls")
+		return
+	}
+	if !validDateRange(w, r) {
+		return
+	}
+	opts := req.fetchOptions(fetchOptionsFromRequest(r))
+	var stream *ndjsonWriter
+	if wantsNdjson(r) {
+		var valid bool
+		if stream, valid = newNdjsonWriter(w, r, Page{}); !valid {
+			return
+		}
+	}
+	key := r.Header.Get(idempotencyHeader)
+	cacheKey := ""
+	if validRequestIdRgx.MatchString(key) {
+		cacheKey = idempotencyCacheKey(key, req.Urls)
+		var stored BatchResult
+		if val, err := readCacheBytes(r.Context(), cacheKey); err == nil && decodeCacheValue(val, &stored) == nil {
+			opts.logger().Printf("idempotent replay key=%s", cacheKey)
+			w.Header().Set("Idempotent-Replayed", "true")
+			if stream != nil {
+				for i := 0; i < len(stored.Pages); i++ {
+					stream.write(stored.Pages[i])
+				}
+				return
+			}
+			writeJson(w, r, stored)
+			return
+		}
+	}
+	var onPage func(Page)
+	if stream != nil {
+		onPage = func(page Page) {
+			stream.write(page)
+		}
+	}
+	data := readBatch(r.Context(), req.Urls, opts, onPage)
+	if len(cacheKey) > 0 && !setCache(r.Context(), cacheKey, data, int64(idempotencyWindowMinutes)) {
+		opts.logger().Printf("cache write failed key=%s", cacheKey)
+	}
+	if stream == nil {
+		writeJson(w, r, data)
+	}
+}
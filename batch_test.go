@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestBatchRunsWithoutConfiguredWorkers(t *testing.T) {
+	useMemoryCache(t)
+	withoutRateLimit(t)
+	previous := batchWorkers
+	batchWorkers = -1
+	defer func() { batchWorkers = previous }()
+	server := serveHtml(t, `<article><h2><a href="/post">Post</a></h2><p>Text.</p></article>`)
+	result := readBatch(context.Background(), []string{server.URL + "/a", server.URL + "/b"}, FetchOptions{}, nil)
+	if len(result.Pages) != 2 {
+		t.Fatalf("expected 2 pages, got %d", len(result.Pages))
+	}
+	for i := 0; i < len(result.Pages); i++ {
+		if !result.Pages[i].Exists || len(result.Pages[i].Error) > 0 {
+			t.Errorf("expected page %d to be read, got error %q", i+1, result.Pages[i].Error)
+		}
+	}
+}
+
+func TestBatchFetchesRepeatedUrlsOnce(t *testing.T) {
+	withoutRateLimit(t)
+	server := newRecordingServer(t)
+	a, b := server.URL+"/a", server.URL+"/b"
+	urls := []string{a, " " + a + " ", b, "", a}
+	if unique := uniqueUrls(urls); len(unique) != 2 || unique[0] != a || unique[1] != b {
+		t.Errorf("expected a then b, got %v", unique)
+	}
+	result := readBatch(context.Background(), urls, FetchOptions{}, nil)
+	if server.hits != 2 {
+		t.Errorf("expected each distinct url fetched once, got %d fetches", server.hits)
+	}
+	if len(result.Pages) != len(urls) || result.Pages[1].Uri != result.Pages[0].Uri || result.Pages[4].Uri != result.Pages[0].Uri || result.Pages[2].Uri != b {
+		t.Fatalf("expected a page for every position in order, got %+v", result.Pages)
+	}
+	if result.Pages[3].Error != "not fetched" {
+		t.Errorf("expected the blank entry to be reported, got %+v", result.Pages[3])
+	}
+}
+
+// streamedPages sends urls to /ws/batch with query and returns the pages streamed back
+func streamedPages(t *testing.T, query string, urls []string) []Page {
+	service := httptest.NewServer(newRouter())
+	defer service.Close()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(service.URL, "http")+"/ws/batch"+query, "", service.URL)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer ws.Close()
+	websocket.JSON.Send(ws, batchRequest{Urls: urls})
+	pages := []Page{}
+	for {
+		var message batchMessage
+		if err := websocket.JSON.Receive(ws, &message); err != nil || message.Type != "page" {
+			return pages
+		}
+		pages = append(pages, *message.Page)
+	}
+}
+
+const datedArticles = `<html><body>
+<article><h2><a href="/new">New</a></h2><time datetime="2024-03-01">1 March</time><p>Recent post.</p></article>
+<article><h2><a href="/old">Old</a></h2><time datetime="2020-03-01">1 March</time><p>Old post.</p></article>
+</body></html>`
+
+func TestStreamedPagesAreFilteredByDate(t *testing.T) {
+	useMemoryCache(t)
+	withoutRateLimit(t)
+	server := serveHtml(t, datedArticles)
+	pages := streamedPages(t, "?since=2024-01-01", []string{server.URL + "/"})
+	if len(pages) != 1 || len(pages[0].Articles) != 1 || pages[0].Articles[0].Uri != "/new" {
+		t.Errorf("expected only the article since the date, got %+v", pages)
+	}
+}
+
+func TestStreamedPagesCapTheirLinks(t *testing.T) {
+	useMemoryCache(t)
+	withoutRateLimit(t)
+	server := serveHtml(t, datedArticles)
+	pages := streamedPages(t, "?maxLinks=1", []string{server.URL + "/"})
+	if len(pages) != 1 || len(pages[0].Links) != 1 || !pages[0].LinksTruncated {
+		t.Errorf("expected a single link flagged as truncated, got %+v", pages)
+	}
+}
+
+func TestStreamedPagesRequireTheLanguage(t *testing.T) {
+	useMemoryCache(t)
+	withoutRateLimit(t)
+	server := serveHtml(t, englishArticle)
+	pages := streamedPages(t, "?requireLang=fr", []string{server.URL + "/"})
+	if len(pages) != 1 || !pages[0].LangMismatch || len(pages[0].Articles) != 0 {
+		t.Errorf("expected the English page emptied, got %+v", pages)
+	}
+}
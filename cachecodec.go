@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+)
+
+// CACHE_COMPRESS gzips cached values, entries written either way remain readable
+var cacheCompress = envBool("CACHE_COMPRESS", false)
+
+// marks a gzipped cache value, JSON values never start with it
+var gzipCacheMarker = []byte("gz:")
+
+// encodeCacheValue marshals data as compact JSON, gzipped when CACHE_COMPRESS is set
+func encodeCacheValue(data interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil || !cacheCompress {
+		return encoded, err
+	}
+	var buf bytes.Buffer
+	buf.Write(gzipCacheMarker)
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(encoded); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeCacheValue unmarshals a cached value into target, decompressing it when marked as gzipped
+func decodeCacheValue(val []byte, target interface{}) error {
+	if bytes.HasPrefix(val, gzipCacheMarker) {
+		zr, err := gzip.NewReader(bytes.NewReader(val[len(gzipCacheMarker):]))
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		if val, err = ioutil.ReadAll(zr); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(val, target)
+}
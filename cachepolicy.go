@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// cachePolicy sets whether an endpoint caches its results and for how long
+type cachePolicy struct {
+	Enabled    bool
+	TtlMinutes int
+}
+
+// only blog pages are cached unless CACHE_POLICY says otherwise
+func defaultCachePolicies() map[string]cachePolicy {
+	return map[string]cachePolicy{
+		"blog": {Enabled: true, TtlMinutes: cacheTtlMinutes},
+	}
+}
+
+// parseCachePolicy reads a name=value entry, where value is on, off or a TTL in minutes
+func parseCachePolicy(entry string) (string, cachePolicy, bool) {
+	parts := strings.SplitN(entry, "=", 2)
+	if len(parts) < 2 {
+		return "", cachePolicy{}, false
+	}
+	name := strings.ToLower(strings.TrimSpace(parts[0]))
+	value := strings.ToLower(strings.TrimSpace(parts[1]))
+	switch value {
+	case "on":
+		return name, cachePolicy{Enabled: true, TtlMinutes: cacheTtlMinutes}, len(name) > 0
+	case "off":
+		return name, cachePolicy{}, len(name) > 0
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes < 0 {
+		return "", cachePolicy{}, false
+	}
+	return name, cachePolicy{Enabled: minutes > 0, TtlMinutes: minutes}, len(name) > 0
+}
+
+// loadCachePolicies overrides the defaults with entries such as blog=1440,discover=60,images=off
+func loadCachePolicies(entries []string) map[string]cachePolicy {
+	policies := defaultCachePolicies()
+	for i := 0; i < len(entries); i++ {
+		name, policy, ok := parseCachePolicy(entries[i])
+		if !ok {
+			log.Printf("ignoring invalid CACHE_POLICY entry %q", entries[i])
+			continue
+		}
+		policies[name] = policy
+	}
+	return policies
+}
+
+var cachePolicies = loadCachePolicies(envList("CACHE_POLICY", []string{}))
+
+// cachePolicyFor returns the policy of an endpoint, endpoints without one are not cached
+func cachePolicyFor(endpoint string) cachePolicy {
+	if policy, exists := cachePolicies[endpoint]; exists {
+		return policy
+	}
+	return cachePolicy{TtlMinutes: cacheTtlMinutes}
+}
+
+// endpointCacheKey namespaces results of endpoints other than blog, whose keys are the bare page path.
+// CACHE_QUERY applies to the uri alone, so the option suffix is always kept whole
+func endpointCacheKey(endpoint string, uri string, suffix string) string {
+	return cachePrefix + "~" + endpoint + ":" + cacheKeyUri(uri) + suffix
+}
+
+// readEndpointCache loads a cached result into target when the endpoint's policy enables caching
+// and the fetch is not private
+func readEndpointCache(ctx context.Context, endpoint string, uri string, target interface{}, opts FetchOptions) bool {
+	if !cachePolicyFor(endpoint).Enabled || opts.isPrivate() {
+		return false
+	}
+	key := endpointCacheKey(endpoint, uri, opts.cacheSuffix())
+	val, err := readCacheBytes(ctx, key)
+	if err != nil {
+		return false
+	}
+	if decodeCacheValue(val, target) != nil {
+		return false
+	}
+	opts.logger().Printf("cache hit key=%s", key)
+	return true
+}
+
+// readCacheBytes returns the raw stored value of a key, to be read with decodeCacheValue
+func readCacheBytes(parent context.Context, key string) ([]byte, error) {
+	ctx, cancel := redisContext(parent)
+	defer cancel()
+	return cacheStore().Get(ctx, key)
+}
+
+// writeEndpointCache stores a result for the TTL of the endpoint's policy, unless the fetch is private
+func writeEndpointCache(ctx context.Context, endpoint string, uri string, data interface{}, opts FetchOptions) {
+	policy := cachePolicyFor(endpoint)
+	if !policy.Enabled || opts.isPrivate() {
+		return
+	}
+	key := endpointCacheKey(endpoint, uri, opts.cacheSuffix())
+	if !setCache(ctx, key, data, int64(policy.TtlMinutes)) {
+		opts.logger().Printf("cache write failed key=%s", key)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestParseCachePolicy(t *testing.T) {
+	cases := []struct {
+		entry   string
+		name    string
+		policy  cachePolicy
+		isValid bool
+	}{
+		{" Discover = 60 ", "discover", cachePolicy{Enabled: true, TtlMinutes: 60}, true},
+		{"images=ON", "images", cachePolicy{Enabled: true, TtlMinutes: cacheTtlMinutes}, true},
+		{"blog=off", "blog", cachePolicy{}, true},
+		{"head=0", "head", cachePolicy{TtlMinutes: 0}, true},
+		{"head=-5", "", cachePolicy{}, false},
+		{"head=soon", "", cachePolicy{}, false},
+		{"=60", "", cachePolicy{}, false},
+		{"discover", "", cachePolicy{}, false},
+	}
+	for _, c := range cases {
+		name, policy, valid := parseCachePolicy(c.entry)
+		if valid != c.isValid || (valid && (name != c.name || policy != c.policy)) {
+			t.Errorf("%q: expected %q %+v valid=%v, got %q %+v valid=%v", c.entry, c.name, c.policy, c.isValid, name, policy, valid)
+		}
+	}
+}
+
+func TestCachePolicyResolution(t *testing.T) {
+	previous := cachePolicies
+	cachePolicies = loadCachePolicies([]string{"discover=60", "images=off", "bogus", "blog=15"})
+	defer func() { cachePolicies = previous }()
+	if policy := cachePolicyFor("discover"); !policy.Enabled || policy.TtlMinutes != 60 {
+		t.Errorf("expected discover cached for 60 minutes, got %+v", policy)
+	}
+	if policy := cachePolicyFor("blog"); !policy.Enabled || policy.TtlMinutes != 15 {
+		t.Errorf("expected the blog default to be overridden, got %+v", policy)
+	}
+	if cachePolicyFor("images").Enabled || cachePolicyFor("head").Enabled {
+		t.Errorf("expected images switched off and head, without a policy, not cached")
+	}
+	if defaults := loadCachePolicies(nil); len(defaults) != 1 || !defaults["blog"].Enabled {
+		t.Errorf("expected only blog pages cached by default, got %+v", defaults)
+	}
+}
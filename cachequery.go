@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// cacheQuery sets which query params of a target page are part of its cache key:
+// all keeps the query string as requested, none drops it, and a list such as page,sort
+// keeps only those params, so tracking params do not split the cache
+var cacheQuery = envList("CACHE_QUERY", []string{"all"})
+
+// cacheKeyUri applies the CACHE_QUERY setting to the query string of a page path or uri
+func cacheKeyUri(uri string) string {
+	parts := strings.SplitN(uri, "?", 2)
+	if len(parts) < 2 || len(cacheQuery) < 1 {
+		return uri
+	}
+	switch strings.ToLower(cacheQuery[0]) {
+	case "all":
+		return uri
+	case "none":
+		return parts[0]
+	}
+	query, err := url.ParseQuery(parts[1])
+	if err != nil {
+		return parts[0]
+	}
+	kept := url.Values{}
+	for i := 0; i < len(cacheQuery); i++ {
+		if values, exists := query[cacheQuery[i]]; exists {
+			kept[cacheQuery[i]] = values
+		}
+	}
+	if len(kept) < 1 {
+		return parts[0]
+	}
+	return parts[0] + "?" + kept.Encode()
+}
@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func withCacheQuery(t *testing.T, setting ...string) {
+	previous := cacheQuery
+	cacheQuery = setting
+	t.Cleanup(func() {
+		cacheQuery = previous
+	})
+}
+
+func TestCacheKeyUriAppliesCacheQuery(t *testing.T) {
+	cases := []struct {
+		setting []string
+		want    string
+	}{
+		{[]string{"all"}, "example.com/list?utm_source=x&page=2"},
+		{[]string{"none"}, "example.com/list"},
+		{[]string{"page"}, "example.com/list?page=2"},
+		{[]string{"sort"}, "example.com/list"},
+	}
+	for _, c := range cases {
+		withCacheQuery(t, c.setting...)
+		if got := cacheKeyUri("example.com/list?utm_source=x&page=2"); got != c.want {
+			t.Errorf("CACHE_QUERY=%v: expected %q, got %q", c.setting, c.want, got)
+		}
+	}
+}
+
+func TestEndpointCacheKeyKeepsTheOptionSuffix(t *testing.T) {
+	withCacheQuery(t, "none")
+	french := endpointCacheKey("discover", "https://example.com/list?page=2", ":lang=fr")
+	if french != cachePrefix+"~discover:https://example.com/list:lang=fr" {
+		t.Errorf("expected the query dropped and the suffix kept, got %q", french)
+	}
+	withCacheQuery(t, "page")
+	german := endpointCacheKey("discover", "https://example.com/list?page=2", ":lang=de")
+	if german != cachePrefix+"~discover:https://example.com/list?page=2:lang=de" {
+		t.Errorf("expected the allowed param and the suffix kept, got %q", german)
+	}
+}
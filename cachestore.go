@@ -0,0 +1,448 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// errCacheMiss is returned by every backend for a key that is not stored or has expired
+var errCacheMiss = errors.New("cache miss")
+
+// cacheBackend stores the cached pages, endpoint results and page history.
+// TTL reports -2 for a missing key and -1 for a key without expiry, as Redis does
+type cacheBackend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	TTL(ctx context.Context, keys ...string) ([]time.Duration, error)
+	// Scan calls each with every key under prefix until it returns false
+	Scan(ctx context.Context, prefix string, each func(key string) bool) error
+	Delete(ctx context.Context, keys ...string) (int64, error)
+	// Push prepends value to the list at key, keeping at most maxLen items
+	Push(ctx context.Context, key string, value []byte, maxLen int) error
+	// Range returns the items of the list at key, newest first
+	Range(ctx context.Context, key string) ([][]byte, error)
+}
+
+// CACHE_BACKEND selects redis, the default, or memory for a single instance without Redis.
+// When Redis cannot be reached, requests use REDIS_FALLBACK, memory or none, for REDIS_RETRY_SECONDS
+var cacheBackendName = strings.ToLower(envString("CACHE_BACKEND", "redis"))
+
+var redisFallbackName = strings.ToLower(envString("REDIS_FALLBACK", "memory"))
+
+var redisRetryInterval = time.Duration(envInt("REDIS_RETRY_SECONDS", 30)) * time.Second
+
+var redisAddr = envString("REDIS_ADDR", "localhost:6379")
+
+// most entries held by the in-memory cache before the least recently used are evicted
+var memoryCacheEntries = envInt("CACHE_MEMORY_ENTRIES", 1000)
+
+var activeCache cacheBackend
+var activeCacheOnce sync.Once
+
+// cacheStore returns the configured backend, built on first use
+func cacheStore() cacheBackend {
+	activeCacheOnce.Do(func() {
+		activeCache = newCacheBackend(cacheBackendName)
+	})
+	return activeCache
+}
+
+// cacheName names the backend answering for backend, the fallback's while Redis is down
+func cacheName(backend cacheBackend) string {
+	switch b := backend.(type) {
+	case *fallbackBackend:
+		return cacheName(b.current())
+	case *redisBackend:
+		return "redis"
+	case *memoryBackend:
+		return "memory"
+	case nullBackend:
+		return "none"
+	}
+	return cacheBackendName
+}
+
+func newCacheBackend(name string) cacheBackend {
+	switch name {
+	case "memory":
+		return newMemoryBackend(memoryCacheEntries)
+	case "none":
+		return nullBackend{}
+	case "redis":
+	default:
+		log.Printf("ignoring invalid CACHE_BACKEND %q", name)
+	}
+	fallback := cacheBackend(nullBackend{})
+	if redisFallbackName == "memory" {
+		fallback = newMemoryBackend(memoryCacheEntries)
+	}
+	return &fallbackBackend{primary: newRedisBackend(), fallback: fallback}
+}
+
+type redisBackend struct {
+	client *redis.Client
+}
+
+// newRedisBackend shares one client, and so one connection pool, between all requests
+func newRedisBackend() *redisBackend {
+	return &redisBackend{client: redis.NewClient(&redis.Options{
+		Addr:     redisAddr,
+		Password: "", // no password set
+		DB:       0,  // use default DB
+	})}
+}
+
+func (rb *redisBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	val, err := rb.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, errCacheMiss
+	}
+	return val, err
+}
+
+func (rb *redisBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return rb.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (rb *redisBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	return rb.client.Expire(ctx, key, ttl).Err()
+}
+
+// TTL looks up all keys in one round trip
+func (rb *redisBackend) TTL(ctx context.Context, keys ...string) ([]time.Duration, error) {
+	pipe := rb.client.Pipeline()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i := 0; i < len(keys); i++ {
+		cmds[i] = pipe.TTL(ctx, keys[i])
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	ttls := make([]time.Duration, len(keys))
+	for i := 0; i < len(keys); i++ {
+		ttls[i] = cmds[i].Val()
+		if cmds[i].Err() != nil {
+			ttls[i] = -2
+		}
+	}
+	return ttls, nil
+}
+
+// Scan iterates incrementally so Redis is never blocked by a large keyspace
+func (rb *redisBackend) Scan(ctx context.Context, prefix string, each func(key string) bool) error {
+	iter := rb.client.Scan(ctx, 0, escapeKeyPattern(prefix)+"*", scanBatchSize).Iterator()
+	for iter.Next(ctx) {
+		if !each(iter.Val()) {
+			return nil
+		}
+	}
+	return iter.Err()
+}
+
+func (rb *redisBackend) Delete(ctx context.Context, keys ...string) (int64, error) {
+	return rb.client.Del(ctx, keys...).Result()
+}
+
+func (rb *redisBackend) Push(ctx context.Context, key string, value []byte, maxLen int) error {
+	pipe := rb.client.TxPipeline()
+	pipe.LPush(ctx, key, value)
+	pipe.LTrim(ctx, key, 0, int64(maxLen-1))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (rb *redisBackend) Range(ctx context.Context, key string) ([][]byte, error) {
+	items, err := rb.client.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(items))
+	for i := 0; i < len(items); i++ {
+		values[i] = []byte(items[i])
+	}
+	return values, nil
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	items     [][]byte
+	expiresAt time.Time
+}
+
+func (me *memoryEntry) expired(now time.Time) bool {
+	return !me.expiresAt.IsZero() && !now.Before(me.expiresAt)
+}
+
+// memoryBackend is a bounded least recently used cache held by this process only
+type memoryBackend struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+func newMemoryBackend(capacity int) *memoryBackend {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &memoryBackend{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// lookup returns the live entry of key, marking it as recently used, the lock must be held
+func (mb *memoryBackend) lookup(key string) *memoryEntry {
+	element, exists := mb.entries[key]
+	if !exists {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if entry.expired(time.Now()) {
+		mb.order.Remove(element)
+		delete(mb.entries, key)
+		return nil
+	}
+	mb.order.MoveToFront(element)
+	return entry
+}
+
+// store adds or replaces the entry of its key, evicting the least recently used beyond capacity
+func (mb *memoryBackend) store(entry *memoryEntry) {
+	if element, exists := mb.entries[entry.key]; exists {
+		element.Value = entry
+		mb.order.MoveToFront(element)
+		return
+	}
+	mb.entries[entry.key] = mb.order.PushFront(entry)
+	for mb.order.Len() > mb.capacity {
+		oldest := mb.order.Back()
+		mb.order.Remove(oldest)
+		delete(mb.entries, oldest.Value.(*memoryEntry).key)
+	}
+}
+
+func expiryFor(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+func (mb *memoryBackend) Get(_ context.Context, key string) ([]byte, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	entry := mb.lookup(key)
+	if entry == nil || entry.value == nil {
+		return nil, errCacheMiss
+	}
+	return entry.value, nil
+}
+
+func (mb *memoryBackend) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.store(&memoryEntry{key: key, value: value, expiresAt: expiryFor(ttl)})
+	return nil
+}
+
+func (mb *memoryBackend) Expire(_ context.Context, key string, ttl time.Duration) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if entry := mb.lookup(key); entry != nil {
+		entry.expiresAt = expiryFor(ttl)
+	}
+	return nil
+}
+
+func (mb *memoryBackend) TTL(_ context.Context, keys ...string) ([]time.Duration, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	ttls := make([]time.Duration, len(keys))
+	for i := 0; i < len(keys); i++ {
+		entry := mb.lookup(keys[i])
+		switch {
+		case entry == nil:
+			ttls[i] = -2
+		case entry.expiresAt.IsZero():
+			ttls[i] = -1
+		default:
+			ttls[i] = time.Until(entry.expiresAt)
+		}
+	}
+	return ttls, nil
+}
+
+// Scan works on a snapshot of the keys, so each may delete entries
+func (mb *memoryBackend) Scan(_ context.Context, prefix string, each func(key string) bool) error {
+	mb.mu.Lock()
+	keys := []string{}
+	now := time.Now()
+	for key, element := range mb.entries {
+		if strings.HasPrefix(key, prefix) && !element.Value.(*memoryEntry).expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	mb.mu.Unlock()
+	for i := 0; i < len(keys); i++ {
+		if !each(keys[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (mb *memoryBackend) Delete(_ context.Context, keys ...string) (int64, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	var removed int64
+	for i := 0; i < len(keys); i++ {
+		if element, exists := mb.entries[keys[i]]; exists {
+			mb.order.Remove(element)
+			delete(mb.entries, keys[i])
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (mb *memoryBackend) Push(_ context.Context, key string, value []byte, maxLen int) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	items := [][]byte{value}
+	if entry := mb.lookup(key); entry != nil {
+		items = append(items, entry.items...)
+	}
+	if maxLen > 0 && len(items) > maxLen {
+		items = items[:maxLen]
+	}
+	mb.store(&memoryEntry{key: key, items: items})
+	return nil
+}
+
+func (mb *memoryBackend) Range(_ context.Context, key string) ([][]byte, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if entry := mb.lookup(key); entry != nil {
+		return append([][]byte{}, entry.items...), nil
+	}
+	return [][]byte{}, nil
+}
+
+// nullBackend stores nothing, so every page is read live
+type nullBackend struct{}
+
+func (nullBackend) Get(context.Context, string) ([]byte, error) { return nil, errCacheMiss }
+
+func (nullBackend) Set(context.Context, string, []byte, time.Duration) error { return nil }
+
+func (nullBackend) Expire(context.Context, string, time.Duration) error { return nil }
+
+func (nullBackend) TTL(_ context.Context, keys ...string) ([]time.Duration, error) {
+	ttls := make([]time.Duration, len(keys))
+	for i := 0; i < len(ttls); i++ {
+		ttls[i] = -2
+	}
+	return ttls, nil
+}
+
+func (nullBackend) Scan(context.Context, string, func(string) bool) error { return nil }
+
+func (nullBackend) Delete(context.Context, ...string) (int64, error) { return 0, nil }
+
+func (nullBackend) Push(context.Context, string, []byte, int) error { return nil }
+
+func (nullBackend) Range(context.Context, string) ([][]byte, error) { return [][]byte{}, nil }
+
+// fallbackBackend uses Redis while it answers and switches to the fallback for redisRetryInterval
+// after a failed call, so an unreachable Redis does not delay every request by its timeout
+type fallbackBackend struct {
+	primary   cacheBackend
+	fallback  cacheBackend
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (fb *fallbackBackend) current() cacheBackend {
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if time.Now().Before(fb.downUntil) {
+		return fb.fallback
+	}
+	return fb.primary
+}
+
+// check marks the primary as down after an error other than a miss or a cancelled request
+func (fb *fallbackBackend) check(ctx context.Context, backend cacheBackend, err error) {
+	if err == nil || err == errCacheMiss || backend != fb.primary || ctx.Err() == context.Canceled {
+		return
+	}
+	fb.mu.Lock()
+	defer fb.mu.Unlock()
+	if time.Now().After(fb.downUntil) {
+		log.Printf("redis unavailable, using the %s cache for %s error=%q", redisFallbackName, redisRetryInterval, err.Error())
+	}
+	fb.downUntil = time.Now().Add(redisRetryInterval)
+}
+
+func (fb *fallbackBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	backend := fb.current()
+	val, err := backend.Get(ctx, key)
+	fb.check(ctx, backend, err)
+	return val, err
+}
+
+func (fb *fallbackBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	backend := fb.current()
+	err := backend.Set(ctx, key, value, ttl)
+	fb.check(ctx, backend, err)
+	return err
+}
+
+func (fb *fallbackBackend) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	backend := fb.current()
+	err := backend.Expire(ctx, key, ttl)
+	fb.check(ctx, backend, err)
+	return err
+}
+
+func (fb *fallbackBackend) TTL(ctx context.Context, keys ...string) ([]time.Duration, error) {
+	backend := fb.current()
+	ttls, err := backend.TTL(ctx, keys...)
+	fb.check(ctx, backend, err)
+	return ttls, err
+}
+
+func (fb *fallbackBackend) Scan(ctx context.Context, prefix string, each func(key string) bool) error {
+	backend := fb.current()
+	err := backend.Scan(ctx, prefix, each)
+	fb.check(ctx, backend, err)
+	return err
+}
+
+func (fb *fallbackBackend) Delete(ctx context.Context, keys ...string) (int64, error) {
+	backend := fb.current()
+	removed, err := backend.Delete(ctx, keys...)
+	fb.check(ctx, backend, err)
+	return removed, err
+}
+
+func (fb *fallbackBackend) Push(ctx context.Context, key string, value []byte, maxLen int) error {
+	backend := fb.current()
+	err := backend.Push(ctx, key, value, maxLen)
+	fb.check(ctx, backend, err)
+	return err
+}
+
+func (fb *fallbackBackend) Range(ctx context.Context, key string) ([][]byte, error) {
+	backend := fb.current()
+	items, err := backend.Range(ctx, key)
+	fb.check(ctx, backend, err)
+	return items, err
+}
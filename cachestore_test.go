@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stalledBackend stands in for a Redis that never answers, each call lasting until its context ends
+type stalledBackend struct {
+	nullBackend
+	calls int
+}
+
+func (sb *stalledBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	sb.calls++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (sb *stalledBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	sb.calls++
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestSlowRedisSwitchesToTheFallback(t *testing.T) {
+	primary := &stalledBackend{}
+	fallback := newMemoryBackend(10)
+	cache := &fallbackBackend{primary: primary, fallback: fallback}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Get(ctx, "key"); err != context.DeadlineExceeded {
+		t.Fatalf("expected the stalled read to time out, got %v", err)
+	}
+	start := time.Now()
+	if err := cache.Set(context.Background(), "key", []byte("value"), time.Minute); err != nil {
+		t.Fatalf("expected the fallback to store the value, got %v", err)
+	}
+	val, err := cache.Get(context.Background(), "key")
+	if err != nil || string(val) != "value" || primary.calls != 1 {
+		t.Errorf("expected the value from the fallback without calling Redis again, got %q, %v after %d calls", val, err, primary.calls)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("expected the fallback to answer at once, took %s", elapsed)
+	}
+}
+
+// failingBackend stands in for an unreachable Redis
+type failingBackend struct {
+	nullBackend
+}
+
+func (failingBackend) Get(ctx context.Context, key string) ([]byte, error) {
+	return nil, errors.New("dial tcp: connection refused")
+}
+
+func TestUnavailableRedisIsRetriedAfterTheInterval(t *testing.T) {
+	previous := redisRetryInterval
+	redisRetryInterval = 20 * time.Millisecond
+	defer func() { redisRetryInterval = previous }()
+	cache := &fallbackBackend{primary: failingBackend{}, fallback: newMemoryBackend(10)}
+	cache.Get(context.Background(), "key")
+	if cache.current() != cache.fallback {
+		t.Fatalf("expected the fallback after a failed call")
+	}
+	if _, err := cache.Get(context.Background(), "key"); err != errCacheMiss {
+		t.Errorf("expected a plain miss from the fallback, got %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if cache.current() != cache.primary {
+		t.Errorf("expected Redis to be tried again after the retry interval")
+	}
+}
+
+func TestCachedHeaderNamesTheFallbackWhileRedisIsDown(t *testing.T) {
+	previous := cacheStore()
+	activeCache = &fallbackBackend{primary: failingBackend{}, fallback: newMemoryBackend(10)}
+	defer func() { activeCache = previous }()
+	server := newRecordingServer(t)
+	getRoute(blogRoute(server.URL+"/post", "cached"))
+	if cached := getRoute(blogRoute(server.URL+"/post", "cached")).Header().Get("cached"); cached != "memory" {
+		t.Errorf("expected the page served from memory to say so, got %q", cached)
+	}
+}
+
+func TestCancelledRequestsDoNotSwitchBackends(t *testing.T) {
+	cache := &fallbackBackend{primary: &stalledBackend{}, fallback: newMemoryBackend(10)}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	cache.Get(ctx, "key")
+	if cache.current() != cache.primary {
+		t.Errorf("expected a request cancelled by its client to keep Redis in use")
+	}
+}
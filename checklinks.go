@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gorilla/mux"
+	"github.com/headzoo/surf/browser"
+)
+
+// links checked at once, kept modest so a site is not flooded with HEAD requests
+var checkLinksConcurrency = envInt("CHECK_LINKS_CONCURRENCY", 5)
+
+// most links checked on a page, the rest are reported in a warning
+var checkLinksMax = envInt("CHECK_LINKS_MAX", 200)
+
+type LinkCheck struct {
+	Uri        string `json:"uri"`
+	StatusCode int    `json:"statusCode"`
+	Broken     bool   `json:"broken"`
+}
+
+type LinkCheckResult struct {
+	Uri     string      `json:"uri"`
+	Error   string      `json:"error,omitempty"`
+	Checked []LinkCheck `json:"checked"`
+	Broken  []LinkCheck `json:"broken"`
+	// Skipped lists links not checked because their host stayed busy past HOST_MAX_WAIT_SECONDS
+	Skipped  []string `json:"skipped"`
+	Warnings []string `json:"warnings"`
+}
+
+// pageWebLinks returns the distinct absolute http and https links of the page in document order
+func pageWebLinks(bow *browser.Browser, uri string) []string {
+	links := []string{}
+	bow.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		abs, err := bow.ResolveStringUrl(strings.TrimSpace(s.AttrOr("href", "")))
+		if err != nil {
+			return
+		}
+		linkUrl, err := url.Parse(abs)
+		if err != nil || !isWebUrl(linkUrl) {
+			return
+		}
+		linkUrl.Fragment = ""
+		if abs = linkUrl.String(); abs != uri && !stringInList(links, abs) {
+			links = append(links, abs)
+		}
+	})
+	return links
+}
+
+// linkCheckOptions keeps the forwarded cookies and headers for links on the page's own host,
+// so credentials given for the crawled site are never sent to the sites it links to
+func linkCheckOptions(linkUrl *url.URL, pageUrl *url.URL, opts FetchOptions) FetchOptions {
+	if pageUrl == nil || !isInternalHost(linkUrl, pageUrl) {
+		opts.Cookies = nil
+		opts.Headers = nil
+	}
+	return opts
+}
+
+// checkLinks probes each link with at most CHECK_LINKS_CONCURRENCY requests in flight,
+// each waiting for its turn at the host's rate limiter, and calls report as each completes
+func checkLinks(ctx context.Context, pageUrl *url.URL, links []string, opts FetchOptions, report func(LinkCheck, bool)) {
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	workers := checkLinksConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range jobs {
+				check := LinkCheck{Uri: link}
+				linkUrl, err := url.Parse(link)
+				if err != nil || !hostRateLimiter.wait(ctx, linkUrl.Host, hostMaxWait) {
+					mu.Lock()
+					report(check, false)
+					mu.Unlock()
+					continue
+				}
+				head := readPageHead(ctx, link, linkCheckOptions(linkUrl, pageUrl, opts))
+				check.StatusCode = head.StatusCode
+				check.Broken = !head.Exists
+				mu.Lock()
+				report(check, true)
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < len(links) && ctx.Err() == nil; i++ {
+		jobs <- links[i]
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// readLinkChecks lists the links of a page that are broken, answering 4xx, 5xx or not at all
+func readLinkChecks(ctx context.Context, uri string, opts FetchOptions) LinkCheckResult {
+	result := LinkCheckResult{Uri: uri, Checked: []LinkCheck{}, Broken: []LinkCheck{}, Skipped: []string{}, Warnings: []string{}}
+	bow, err := fetchPage(ctx, uri, opts)
+	if err != nil {
+		result.Error = fetchErrorMessage(err)
+		return result
+	}
+	links := pageWebLinks(bow, uri)
+	if checkLinksMax > 0 && len(links) > checkLinksMax {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("checked the first %d of %d links", checkLinksMax, len(links)))
+		links = links[:checkLinksMax]
+	}
+	checkLinks(ctx, bow.Url(), links, opts, func(check LinkCheck, done bool) {
+		if !done {
+			result.Skipped = append(result.Skipped, check.Uri)
+			return
+		}
+		result.Checked = append(result.Checked, check)
+		if check.Broken {
+			result.Broken = append(result.Broken, check)
+		}
+	})
+	return result
+}
+
+func checkLinksJson(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	data := readLinkChecks(r.Context(), url, fetchOptionsFromRequest(r))
+	writeJson(w, r, data)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// linkListServer serves a page linking to /p0 ... /p<n-1>, calling probe for every other request
+func linkListServer(t *testing.T, n int, probe func(r *http.Request) int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html")
+			for i := 0; i < n; i++ {
+				fmt.Fprintf(w, `<a href="/p%d">page %d</a>`, i, i)
+			}
+			return
+		}
+		w.WriteHeader(probe(r))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckLinksBoundsConcurrency(t *testing.T) {
+	withoutRateLimit(t)
+	previous := checkLinksConcurrency
+	checkLinksConcurrency = 2
+	defer func() { checkLinksConcurrency = previous }()
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	server := linkListServer(t, 10, func(r *http.Request) int {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		if r.URL.Path == "/p3" {
+			return http.StatusNotFound
+		}
+		return http.StatusOK
+	})
+	result := readLinkChecks(context.Background(), server.URL+"/", FetchOptions{})
+	if peak > 2 {
+		t.Errorf("expected at most 2 checks in flight, saw %d", peak)
+	}
+	if len(result.Checked) != 10 || len(result.Skipped) != 0 {
+		t.Errorf("expected 10 links checked and none skipped, got %d and %d", len(result.Checked), len(result.Skipped))
+	}
+	if len(result.Broken) != 1 || !strings.HasSuffix(result.Broken[0].Uri, "/p3") || result.Broken[0].StatusCode != http.StatusNotFound {
+		t.Errorf("expected /p3 to be reported broken, got %+v", result.Broken)
+	}
+}
+
+func TestCheckLinksReportsSkippedLinks(t *testing.T) {
+	previous, previousWait := hostRateLimiter, hostMaxWait
+	hostRateLimiter, hostMaxWait = newHostLimiter(time.Hour), 0
+	defer func() { hostRateLimiter, hostMaxWait = previous, previousWait }()
+	server := linkListServer(t, 3, func(r *http.Request) int { return http.StatusOK })
+	result := readLinkChecks(context.Background(), server.URL+"/", FetchOptions{})
+	if len(result.Checked) != 1 || len(result.Skipped) != 2 {
+		t.Errorf("expected 1 link checked and 2 skipped, got %d and %d", len(result.Checked), len(result.Skipped))
+	}
+}
+
+func TestCheckLinksSendsCredentialsToPageHostOnly(t *testing.T) {
+	withoutRateLimit(t)
+	var mu sync.Mutex
+	cookies := map[string]string{}
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			cookies[name] = r.Header.Get("Cookie")
+			mu.Unlock()
+		}
+	}
+	other := httptest.NewServer(record("other"))
+	defer other.Close()
+	// the same server under another host name stands in for a third-party site
+	otherUri := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/own", record("own"))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<a href="/own">own</a><a href="%s/other">other</a>`, otherUri)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	opts := FetchOptions{Cookies: parseCookieParams([]string{"session=secret"})}
+	readLinkChecks(context.Background(), server.URL+"/", opts)
+	if cookies["own"] != "session=secret" {
+		t.Errorf("expected the session cookie on the page's own host, got %q", cookies["own"])
+	}
+	if cookie, checked := cookies["other"]; !checked || len(cookie) > 0 {
+		t.Errorf("expected the other host to be checked without cookies, got %q (checked %v)", cookie, checked)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+type Comment struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+// comment thread containers of WordPress themes, Disqus and other common blog engines
+const commentContainerSelector = "#comments,.comments-area,.comment-list,.commentlist,#disqus_thread"
+
+// elements holding comment metadata and replies rather than the comment's own text
+const commentChromeSelector = "ol,ul,.children,.comment-meta,.comment-metadata,.comment-author,.reply,form"
+
+// commentThreads returns the outermost comment containers, as themes often nest a list inside #comments
+func commentThreads(bow *browser.Browser) *goquery.Selection {
+	return bow.Find(commentContainerSelector).FilterFunction(func(_ int, s *goquery.Selection) bool {
+		return s.ParentsFiltered(commentContainerSelector).Length() < 1
+	})
+}
+
+func readComment(item *goquery.Selection) Comment {
+	author := item.Find(".comment-author .fn,[itemprop='author'] [itemprop='name']").First()
+	if author.Length() < 1 {
+		author = item.Find(".comment-author,[itemprop='author']").First()
+	}
+	content := item.Find(".comment-content,.comment-text").First()
+	if content.Length() < 1 {
+		content = item.Clone()
+		content.Find(commentChromeSelector).Remove()
+	}
+	return Comment{
+		Author: removeSpaces(author.Text()),
+		Text:   normalizedText(content),
+	}
+}
+
+// readComments counts the comments of the page's threads. When they are listed the threads are
+// then removed, so the listed text is not repeated in an article, while otherwise the page and
+// the links of its comments are left as they were. Disqus threads load with scripts and hold none
+func readComments(bow *browser.Browser, list bool) (int, []Comment) {
+	comments := []Comment{}
+	threads := commentThreads(bow)
+	items := threads.Find("li.comment")
+	if items.Length() < 1 {
+		items = threads.Find(".comment")
+	}
+	if list {
+		items.Each(func(_ int, s *goquery.Selection) {
+			comments = append(comments, readComment(s))
+		})
+	}
+	count := items.Length()
+	if list {
+		threads.Remove()
+	}
+	return count, comments
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// a single WordPress post followed by its comment list, one comment holding a reply
+const wordPressComments = `<html><body>
+<article><h1><a href="/hello-world">Hello world</a></h1><p>Welcome to the post body.</p></article>
+<div id="comments" class="comments-area">
+<ol class="comment-list">
+<li class="comment" id="comment-1"><article class="comment-body">
+<footer class="comment-meta"><div class="comment-author vcard"><b class="fn">Ann</b></div></footer>
+<div class="comment-content"><p>Great post, see <a href="/related">my notes</a>.</p></div>
+</article>
+<ol class="children"><li class="comment" id="comment-2"><article class="comment-body">
+<footer class="comment-meta"><div class="comment-author vcard"><b class="fn">Bob</b></div></footer>
+<div class="comment-content"><p>Thanks Ann.</p></div>
+</article></li></ol>
+</li>
+</ol>
+</div>
+</body></html>`
+
+func TestCommentsAreListedOnRequest(t *testing.T) {
+	server := serveHtml(t, wordPressComments)
+	opts := FetchOptions{Extract: ExtractOptions{Comments: true}}
+	page := readLiveBlogPage(context.Background(), server.URL+"/hello-world", opts)
+	if page.CommentCount != 2 || len(page.Comments) != 2 {
+		t.Fatalf("expected 2 comments counted and listed, got %d and %d", page.CommentCount, len(page.Comments))
+	}
+	if page.Comments[0].Author != "Ann" || page.Comments[1].Text != "Thanks Ann." {
+		t.Errorf("expected Ann's comment and Bob's reply, got %+v", page.Comments)
+	}
+	for i := 0; i < len(page.Articles); i++ {
+		if strings.Contains(page.Articles[i].Content, "Great post") {
+			t.Errorf("expected the listed comments to be kept out of article content")
+		}
+	}
+}
+
+func TestCommentLinksAreKeptWithoutListing(t *testing.T) {
+	server := serveHtml(t, wordPressComments)
+	page := readLiveBlogPage(context.Background(), server.URL+"/hello-world", FetchOptions{})
+	if page.CommentCount != 2 || len(page.Comments) != 0 {
+		t.Errorf("expected 2 comments counted and none listed, got %d and %d", page.CommentCount, len(page.Comments))
+	}
+	if !uriIsInLinkItems(page.Links, "/related") {
+		t.Errorf("expected the link inside a comment in the page links, got %+v", page.Links)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "math"
+
+// confidenceSignals are the observations extractionConfidence weighs
+type confidenceSignals struct {
+	// Articles is the number of articles returned
+	Articles int
+	// ArticleTags is true when the page marks its content with <article> elements
+	ArticleTags bool
+	// Words and ContentWords count the body text and the part of it outside links
+	Words        int
+	ContentWords int
+	// StructuredData is true when the page has JSON-LD or microdata
+	StructuredData bool
+	// Warnings is the number of extraction warnings
+	Warnings int
+}
+
+// extractionConfidence scores from 0 to 1 how unambiguous the extraction was:
+//   - 0.3 when any article was extracted, nothing else counts without one
+//   - 0.2 when the page uses <article> elements rather than fallback selectors
+//   - up to 0.2 in proportion to the share of words outside links, as navigation heavy pages are guesses
+//   - 0.15 when the page declares structured data that confirms it holds an article
+//   - 0.15 when extraction raised no warnings, such as articles without titles
+func extractionConfidence(signals confidenceSignals) float64 {
+	if signals.Articles < 1 {
+		return 0
+	}
+	score := 0.3
+	if signals.ArticleTags {
+		score += 0.2
+	}
+	if signals.Words > 0 {
+		ratio := float64(signals.ContentWords) / float64(signals.Words)
+		if ratio > 1 {
+			ratio = 1
+		}
+		score += 0.2 * ratio
+	}
+	if signals.StructuredData {
+		score += 0.15
+	}
+	if signals.Warnings < 1 {
+		score += 0.15
+	}
+	return math.Round(score*100) / 100
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func envString(key string, fallback string) string {
+	val, exists := os.LookupEnv(key)
+	if !exists || len(strings.TrimSpace(val)) < 1 {
+		return fallback
+	}
+	return strings.TrimSpace(val)
+}
+
+func envInt(key string, fallback int) int {
+	val := envString(key, "")
+	if len(val) < 1 {
+		return fallback
+	}
+	num, err := strconv.Atoi(val)
+	if err != nil {
+		log.Printf("ignoring invalid %s %q", key, val)
+		return fallback
+	}
+	return num
+}
+
+func envBool(key string, fallback bool) bool {
+	val := envString(key, "")
+	if len(val) < 1 {
+		return fallback
+	}
+	flag, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Printf("ignoring invalid %s %q", key, val)
+		return fallback
+	}
+	return flag
+}
+
+func envList(key string, fallback []string) []string {
+	val := envString(key, "")
+	if len(val) < 1 {
+		return fallback
+	}
+	items := []string{}
+	parts := strings.Split(val, ",")
+	for i := 0; i < len(parts); i++ {
+		item := strings.TrimSpace(parts[i])
+		if len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	if len(items) < 1 {
+		return fallback
+	}
+	return items
+}
+
+var defaultScheme = loadDefaultScheme()
+
+var maxRedirects = envInt("MAX_REDIRECTS", 10)
+
+// cache key namespaces, so several environments can share one Redis instance
+var cachePrefix = envString("CACHE_PREFIX", "page:")
+
+var statsCachePrefix = envString("STATS_CACHE_PREFIX", "stats:")
+
+// pages with fewer words outside links than this are flagged as thin content
+var thinContentWords = envInt("THIN_CONTENT_WORDS", 50)
+
+// length of the fallback article excerpt in words
+var excerptWords = envInt("EXCERPT_WORDS", 40)
+
+// sent as Accept-Language when a request has no lang param, empty sends no header
+var defaultLang = envString("DEFAULT_LANG", "")
+
+var cacheTtlMinutes = envInt("CACHE_TTL_MINUTES", 1440)
+
+// bounds each Redis operation so an unresponsive server falls through to a live fetch
+var redisTimeout = time.Duration(envInt("REDIS_TIMEOUT_MS", 2000)) * time.Millisecond
+
+// time allowed to fetch and extract a blog page, 0 disables the limit
+var fetchTimeout = time.Duration(envInt("FETCH_TIMEOUT_SECONDS", 15)) * time.Second
+
+// discovery traverses the whole document, so it is given a longer budget
+var discoverTimeout = time.Duration(envInt("DISCOVER_TIMEOUT_SECONDS", 45)) * time.Second
+
+// pause before refetching a page that had no articles, when the retryEmpty option is set
+var retryEmptyDelay = time.Duration(envInt("RETRY_EMPTY_DELAY_MS", 500)) * time.Millisecond
+
+// articles with shorter titles are dropped, both 0 by default so every titled article is kept
+var minTitleWords = envInt("MIN_TITLE_WORDS", 0)
+
+var minTitleChars = envInt("MIN_TITLE_CHARS", 0)
+
+// article container selectors in order of preference, e.g. ARTICLE_SELECTORS="article,.post,main > div"
+var articleSelectors = envList("ARTICLE_SELECTORS", []string{"article", ".post", "main > div"})
+
+// sidebars, comment threads and widgets whose words are not counted as contentWords on discover
+var boilerplateSelectors = envList("BOILERPLATE_SELECTORS", []string{".sidebar", "#sidebar", "aside", "#comments", ".comments", ".related", ".widget"})
+
+func isValidScheme(scheme string) bool {
+	return scheme == "http" || scheme == "https"
+}
+
+func loadDefaultScheme() string {
+	scheme := strings.ToLower(envString("DEFAULT_SCHEME", "https"))
+	if !isValidScheme(scheme) {
+		log.Printf("ignoring invalid DEFAULT_SCHEME %q, using https", scheme)
+		scheme = "https"
+	}
+	return scheme
+}
+
+// effectiveScheme normalises the scheme path segment, which may be omitted
+func effectiveScheme(scheme string) string {
+	scheme = strings.ToLower(strings.TrimSpace(scheme))
+	if len(scheme) < 1 {
+		return defaultScheme
+	}
+	return scheme
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+var emailRgx = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,24}`)
+
+var validEmailRgx = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,24}$`)
+
+// profile hosts recognised as social links, subdomains such as www. or uk. also match
+var socialHosts = []string{"twitter.com", "x.com", "linkedin.com", "facebook.com", "instagram.com", "github.com"}
+
+func stringInList(items []string, str string) bool {
+	for i := 0; i < len(items); i++ {
+		if items[i] == str {
+			return true
+		}
+	}
+	return false
+}
+
+func addEmail(emails []string, email string) []string {
+	email = strings.ToLower(strings.Trim(email, " ."))
+	if validEmailRgx.MatchString(email) && !stringInList(emails, email) {
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+func extractEmails(bow *browser.Browser) []string {
+	emails := []string{}
+	bow.Find("a[href^='mailto:']").Each(func(_ int, s *goquery.Selection) {
+		href := strings.TrimPrefix(s.AttrOr("href", ""), "mailto:")
+		// drop any ?subject= or cc parameters
+		address := strings.Split(href, "?")[0]
+		if decoded, err := url.QueryUnescape(address); err == nil {
+			address = decoded
+		}
+		for _, part := range strings.Split(address, ",") {
+			emails = addEmail(emails, part)
+		}
+	})
+	body := bow.Find("body").Clone()
+	body.Find("script,style,noscript").Remove()
+	matches := emailRgx.FindAllString(body.Text(), -1)
+	for i := 0; i < len(matches); i++ {
+		emails = addEmail(emails, matches[i])
+	}
+	return emails
+}
+
+func isSocialHost(host string) bool {
+	host = strings.ToLower(host)
+	for i := 0; i < len(socialHosts); i++ {
+		if host == socialHosts[i] || strings.HasSuffix(host, "."+socialHosts[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func extractSocials(links []*browser.Link) []LinkItem {
+	socials := []LinkItem{}
+	for i := 0; i < len(links); i++ {
+		linkUrl := links[i].Url()
+		if !isSocialHost(linkUrl.Hostname()) || len(strings.Trim(linkUrl.Path, "/")) < 1 {
+			continue
+		}
+		uri := linkUrl.String()
+		if !uriIsInLinkItems(socials, uri) {
+			socials = append(socials, makeLinkItem(uri, links[i].Text))
+		}
+	}
+	return socials
+}
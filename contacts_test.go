@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestExtractEmailsDedupesLinksAndText(t *testing.T) {
+	bow := openHtml(t, `<html><body>
+<a href="mailto:Editor@Example.com?subject=Hi">Write to us</a>
+<a href="mailto:sales%40example.com,team@example.com">Sales</a>
+<p>Or write to editor@example.com. Press: press@example.co.uk</p>
+<script>var hidden = "bot@example.com";</script>
+</body></html>`)
+	emails := extractEmails(bow)
+	expected := []string{"editor@example.com", "sales@example.com", "team@example.com", "press@example.co.uk"}
+	if len(emails) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, emails)
+	}
+	for i := 0; i < len(expected); i++ {
+		if emails[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected, emails)
+			break
+		}
+	}
+}
+
+func TestExtractSocialsKeepsProfileLinksOnce(t *testing.T) {
+	bow := openHtml(t, `<html><body>
+<a href="https://twitter.com/">Twitter</a>
+<a href="https://twitter.com/example">Follow us</a>
+<a href="https://www.linkedin.com/company/example">LinkedIn</a>
+<footer><a href="https://twitter.com/example">Twitter</a></footer>
+<a href="https://notgithub.com/example">Lookalike</a>
+</body></html>`)
+	socials := extractSocials(bow.Links())
+	if len(socials) != 2 || socials[0].Uri != "https://twitter.com/example" || socials[0].Title != "Follow us" {
+		t.Errorf("expected the twitter and linkedin profiles once each, got %+v", socials)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// media types the browser will download, e.g. ALLOWED_CONTENT_TYPES="text/html,application/xhtml+xml"
+var allowedContentTypes = envList("ALLOWED_CONTENT_TYPES", []string{"text/html", "application/xhtml+xml"})
+
+type contentTypeError struct {
+	contentType string
+}
+
+func (e *contentTypeError) Error() string {
+	return "content type not allowed: " + e.contentType
+}
+
+// isAllowedContentType matches the media type without parameters such as charset,
+// a missing Content-Type is allowed as the body may still be html
+func isAllowedContentType(header string) bool {
+	if len(strings.TrimSpace(header)) < 1 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for i := 0; i < len(allowedContentTypes); i++ {
+		if strings.EqualFold(mediaType, allowedContentTypes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentTypeGuard closes successful responses of a disallowed type as soon as their headers arrive,
+// so the body is never downloaded
+type contentTypeGuard struct {
+	base http.RoundTripper
+}
+
+func (cg *contentTypeGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := cg.base.RoundTrip(req)
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !isAllowedContentType(contentType) {
+		resp.Body.Close()
+		return nil, &contentTypeError{contentType: contentType}
+	}
+	return resp, nil
+}
+
+func isContentTypeError(err error) (*contentTypeError, bool) {
+	var ctErr *contentTypeError
+	ok := errors.As(err, &ctErr)
+	return ctErr, ok
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type PageCount struct {
+	Uri                string `json:"uri"`
+	Exists             bool   `json:"exists"`
+	Words              int    `json:"words"`
+	Links              int    `json:"links"`
+	Images             int    `json:"images"`
+	ReadingTimeMinutes int    `json:"readingTimeMinutes"`
+}
+
+// average adult silent reading speed used for reading time estimates
+var readingWordsPerMinute = envInt("READING_WORDS_PER_MINUTE", 230)
+
+// readingTimeMinutes rounds up, so any text takes at least a minute
+func readingTimeMinutes(words int) int {
+	if words < 1 || readingWordsPerMinute < 1 {
+		return 0
+	}
+	return (words + readingWordsPerMinute - 1) / readingWordsPerMinute
+}
+
+func readLiveCount(ctx context.Context, uri string, opts FetchOptions) PageCount {
+	count := PageCount{Uri: uri}
+	bow, err := fetchPage(ctx, uri, opts)
+	if err != nil {
+		return count
+	}
+	count.Exists = true
+	count.Words = len(strings.Fields(readBodyText(bow)))
+	count.Links = len(bow.Links())
+	count.Images = bow.Find("img").Length()
+	count.ReadingTimeMinutes = readingTimeMinutes(count.Words)
+	return count
+}
+
+func countPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	opts := fetchOptionsFromRequest(r)
+	var data PageCount
+	if !readEndpointCache(r.Context(), "count", url, &data, opts) {
+		data = readLiveCount(r.Context(), url, opts)
+		writeEndpointCache(r.Context(), "count", url, data, opts)
+	}
+	writeJson(w, r, data)
+}
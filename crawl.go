@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/mux"
+)
+
+type CrawledPage struct {
+	Uri       string `json:"uri"`
+	Exists    bool   `json:"exists"`
+	Title     string `json:"title"`
+	WordCount int    `json:"wordCount"`
+	Error     string `json:"error,omitempty"`
+}
+
+type CrawlResult struct {
+	Uri             string        `json:"uri"`
+	Pages           []CrawledPage `json:"pages"`
+	BudgetExhausted bool          `json:"budgetExhausted"`
+}
+
+// hard cap on the pages fetched by one crawl, whatever ?maxPages asks for
+var crawlMaxPages = envInt("CRAWL_MAX_PAGES", 100)
+
+var crawlDefaultPages = envInt("CRAWL_DEFAULT_PAGES", 20)
+
+// number of pages of a crawl fetched concurrently
+var crawlWorkers = envInt("CRAWL_WORKERS", 4)
+
+// fetchBudget counts down the fetches left to a crawl and is shared by its workers
+type fetchBudget struct {
+	remaining int64
+}
+
+func newFetchBudget(requested int) *fetchBudget {
+	if requested < 1 {
+		requested = crawlDefaultPages
+	}
+	if requested > crawlMaxPages {
+		requested = crawlMaxPages
+	}
+	return &fetchBudget{remaining: int64(requested)}
+}
+
+// take reserves one fetch, returning false once the budget is spent
+func (b *fetchBudget) take() bool {
+	return atomic.AddInt64(&b.remaining, -1) >= 0
+}
+
+// crawlPage fetches one page and returns the web links on the same host, without fragments
+func crawlPage(ctx context.Context, uri string, opts FetchOptions) (CrawledPage, []string) {
+	page := CrawledPage{Uri: uri}
+	links := []string{}
+	bow, err := fetchPage(ctx, uri, opts)
+	if err != nil {
+		page.Error = fetchErrorMessage(err)
+		return page, links
+	}
+	page.Exists = true
+	page.Title = bow.Title()
+	page.WordCount = len(strings.Fields(readBodyText(bow)))
+	pageUrl := bow.Url()
+	linkObjs := bow.Links()
+	for i := 0; i < len(linkObjs); i++ {
+		linkUrl := *linkObjs[i].Url()
+		if !isWebUrl(&linkUrl) || pageUrl == nil || !isInternalHost(&linkUrl, pageUrl) {
+			continue
+		}
+		linkUrl.Fragment = ""
+		links = append(links, linkUrl.String())
+	}
+	return page, links
+}
+
+// crawlSite fetches pages of the start uri's host breadth first until none are left or the budget runs out.
+// onPage, when set, is called with each page as it is fetched, never concurrently
+func crawlSite(ctx context.Context, uri string, maxPages int, opts FetchOptions, onPage func(CrawledPage)) CrawlResult {
+	result := CrawlResult{Uri: uri, Pages: []CrawledPage{}}
+	opts.Polite = true
+	// with no worker the first page could never be handed over
+	workers := crawlWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	budget := newFetchBudget(maxPages)
+	seen := map[string]bool{uri: true}
+	frontier := []string{uri}
+	var mu sync.Mutex
+	for len(frontier) > 0 && ctx.Err() == nil {
+		next := []string{}
+		jobs := make(chan string)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for target := range jobs {
+					if !budget.take() {
+						mu.Lock()
+						result.BudgetExhausted = true
+						mu.Unlock()
+						continue
+					}
+					page, links := crawlPage(ctx, target, opts)
+					mu.Lock()
+					result.Pages = append(result.Pages, page)
+					if onPage != nil {
+						onPage(page)
+					}
+					for i := 0; i < len(links); i++ {
+						if !seen[links[i]] {
+							seen[links[i]] = true
+							next = append(next, links[i])
+						}
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for i := 0; i < len(frontier); i++ {
+			jobs <- frontier[i]
+		}
+		close(jobs)
+		wg.Wait()
+		if result.BudgetExhausted {
+			break
+		}
+		frontier = next
+	}
+	return result
+}
+
+func crawlJson(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	if wantsNdjson(r) {
+		stream, valid := newNdjsonWriter(w, r, CrawledPage{})
+		if !valid {
+			return
+		}
+		crawlSite(r.Context(), url, queryInt(r, "maxPages"), fetchOptionsFromRequest(r), func(page CrawledPage) {
+			stream.write(page)
+		})
+		return
+	}
+	data := crawlSite(r.Context(), url, queryInt(r, "maxPages"), fetchOptionsFromRequest(r), nil)
+	writeJson(w, r, data)
+}
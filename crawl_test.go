@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCrawlRunsWithoutConfiguredWorkers(t *testing.T) {
+	withoutRateLimit(t)
+	previous := crawlWorkers
+	crawlWorkers = 0
+	defer func() { crawlWorkers = previous }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<a href="/a">A</a><a href="/b">B</a>`)
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result := crawlSite(ctx, server.URL+"/", 10, FetchOptions{}, nil)
+	if ctx.Err() != nil || len(result.Pages) != 3 {
+		t.Errorf("expected the 3 pages to be crawled by one worker, got %d", len(result.Pages))
+	}
+}
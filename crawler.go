@@ -2,40 +2,128 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
-	"github.com/go-redis/redis/v8"
 	"github.com/gorilla/mux"
 	"github.com/headzoo/surf/browser"
-	"gopkg.in/headzoo/surf.v1"
 )
 
 type Article struct {
-	Title   string     `json:"title"`
-	Uri     string     `json:"uri"`
-	Content string     `json:"content"`
-	Links   []LinkItem `json:"links"`
+	Title            string     `json:"title"`
+	Uri              string     `json:"uri"`
+	Content          string     `json:"content"`
+	Excerpt          string     `json:"excerpt"`
+	Links            []LinkItem `json:"links"`
+	ContentTruncated bool       `json:"contentTruncated,omitempty"`
+	Videos           []string   `json:"videos"`
+	Paywalled        bool       `json:"paywalled,omitempty"`
+	Published        string     `json:"published,omitempty"`
+	CommentCount     *int       `json:"commentCount,omitempty"`
+	ShareCount       *int       `json:"shareCount,omitempty"`
 }
 
 type Page struct {
-	Uri      string     `json:"uri"`
-	Exists   bool       `json:"exists"`
-	Cached   bool       `json:"cached"`
-	Title    string     `json:"title"`
-	Articles []Article  `json:"articles"`
-	Links    []LinkItem `json:"links"`
+	Uri              string                `json:"uri"`
+	Exists           bool                  `json:"exists"`
+	Cached           bool                  `json:"cached"`
+	Title            string                `json:"title"`
+	Articles         []Article             `json:"articles"`
+	Links            []LinkItem            `json:"links"`
+	Emails           []string              `json:"emails"`
+	Socials          []LinkItem            `json:"socials"`
+	Warnings         []string              `json:"warnings"`
+	Error            string                `json:"error,omitempty"`
+	Canonical        string                `json:"canonical,omitempty"`
+	AmpUri           string                `json:"ampUri,omitempty"`
+	Variant          string                `json:"variant,omitempty"`
+	FetchedAt        string                `json:"fetchedAt"`
+	WordCount        int                   `json:"wordCount"`
+	ContentHash      string                `json:"contentHash"`
+	ThinContent      bool                  `json:"thinContent"`
+	Timings          []PhaseTiming         `json:"timings,omitempty"`
+	Timing           *TimingSummary        `json:"timing,omitempty"`
+	Headers          map[string]string     `json:"headers,omitempty"`
+	SoftError        bool                  `json:"softError,omitempty"`
+	Direction        string                `json:"direction,omitempty"`
+	Media            []MediaItem           `json:"media"`
+	Truncated        bool                  `json:"truncated,omitempty"`
+	Microdata        []MicrodataItem       `json:"microdata"`
+	Confidence       float64               `json:"confidence"`
+	Rendered         bool                  `json:"rendered,omitempty"`
+	CommentCount     int                   `json:"commentCount"`
+	Comments         []Comment             `json:"comments,omitempty"`
+	LinksTruncated   bool                  `json:"linksTruncated,omitempty"`
+	TitleSource      string                `json:"titleSource,omitempty"`
+	Recipe           *Recipe               `json:"recipe,omitempty"`
+	Product          *Product              `json:"product,omitempty"`
+	ShareCount       *int                  `json:"shareCount,omitempty"`
+	ImagesMissingAlt int                   `json:"imagesMissingAlt"`
+	AltTexts         []string              `json:"altTexts,omitempty"`
+	Lang             string                `json:"lang,omitempty"`
+	LinkGroups       map[string][]LinkItem `json:"linkGroups,omitempty"`
+	LangUncertain    bool                  `json:"langUncertain,omitempty"`
+	LangMismatch     bool                  `json:"langMismatch,omitempty"`
+	// notModified is set when a conditional fetch was answered with 304
+	notModified bool
 }
 
 func (p *Page) setCached() {
 	p.Cached = true
 }
 
+// limitLinks keeps the first max distinct links, 0 leaves them uncapped.
+// It applies to the response only, so cached pages keep every link
+func (p *Page) limitLinks(max int) {
+	if max > 0 && len(p.Links) > max {
+		p.Links = p.Links[:max]
+		p.LinksTruncated = true
+	}
+}
+
+// ensureSlices replaces nil lists, e.g. from entries cached before lists were always initialised,
+// so they encode as [] rather than null
+func (p *Page) ensureSlices() {
+	if p.Articles == nil {
+		p.Articles = []Article{}
+	}
+	if p.Links == nil {
+		p.Links = []LinkItem{}
+	}
+	if p.Emails == nil {
+		p.Emails = []string{}
+	}
+	if p.Socials == nil {
+		p.Socials = []LinkItem{}
+	}
+	if p.Warnings == nil {
+		p.Warnings = []string{}
+	}
+	if p.Media == nil {
+		p.Media = []MediaItem{}
+	}
+	if p.Microdata == nil {
+		p.Microdata = []MicrodataItem{}
+	}
+	for i := 0; i < len(p.Articles); i++ {
+		if p.Articles[i].Links == nil {
+			p.Articles[i].Links = []LinkItem{}
+		}
+		if p.Articles[i].Videos == nil {
+			p.Articles[i].Videos = []string{}
+		}
+	}
+}
+
 type CountItem struct {
 	Key   string `json:"key"`
 	Value int    `json:"value"`
@@ -47,16 +135,20 @@ func (ci *CountItem) increment() CountItem {
 }
 
 type PageStats struct {
-	Uri    string      `json:"uri"`
-	Exists bool        `json:"exists"`
-	Counts []CountItem `json:"counts"`
-	Words  []CountItem `json:"words"`
+	Uri         string               `json:"uri"`
+	Exists      bool                 `json:"exists"`
+	Error       string               `json:"error,omitempty"`
+	Counts      []CountItem          `json:"counts"`
+	Words       []CountItem          `json:"words"`
+	RawWords    []CountItem          `json:"rawWords"`
+	Suggestions []SelectorSuggestion `json:"suggestions"`
+	AltTexts    []string             `json:"altTexts,omitempty"`
 }
 
 func newPageStats(uri string, exists bool) PageStats {
-	var counts []CountItem
-	var words []CountItem
-	return PageStats{Uri: uri, Exists: exists, Counts: counts, Words: words}
+	counts := []CountItem{}
+	words := []CountItem{}
+	return PageStats{Uri: uri, Exists: exists, Counts: counts, Words: words, RawWords: []CountItem{}, Suggestions: []SelectorSuggestion{}}
 }
 
 func (ps *PageStats) addCountItem(key string, val int) PageStats {
@@ -74,20 +166,28 @@ func findCountItemIndex(word string, countItems []CountItem) int {
 	return -1
 }
 
-func (ps *PageStats) setWords(words []string) PageStats {
-	var wcs []CountItem
+// setWords counts every word into RawWords and the words outside the stopword set into Words
+func (ps *PageStats) setWords(words []string, stopwords map[string]bool) PageStats {
+	wcs := []CountItem{}
+	raw := []CountItem{}
 	for i := 0; i < len(words); i++ {
 		word := strings.ToLower(strings.Trim(words[i], ".,;"))
 		if len(word) > 0 {
-			relIndex := findCountItemIndex(word, wcs)
+			relIndex := findCountItemIndex(word, raw)
 			if relIndex < 0 {
-				wcs = append(wcs, CountItem{Key: word, Value: 1})
+				raw = append(raw, CountItem{Key: word, Value: 1})
 			} else {
-				wcs[relIndex].increment()
+				raw[relIndex].increment()
 			}
 		}
 	}
+	for i := 0; i < len(raw); i++ {
+		if !stopwords[raw[i].Key] {
+			wcs = append(wcs, raw[i])
+		}
+	}
 	ps.Words = wcs
+	ps.RawWords = raw
 	return *ps
 }
 
@@ -108,7 +208,16 @@ func extractClasses(selection *goquery.Selection) []string {
 	return classList
 }
 
+// ancestors climbed to build the parent path of a block, overridable with CLASS_PATH_MAX_DEPTH
+var classPathMaxDepth = envInt("CLASS_PATH_MAX_DEPTH", 10)
+
 func buildClassesIdSet(selection *goquery.Selection) ClassesIdSet {
+	return buildClassesIdSetWithin(selection, classPathMaxDepth)
+}
+
+// buildClassesIdSetWithin stops the parent path after depth ancestors,
+// so deeply nested markup yields a shorter path instead of climbing to the root
+func buildClassesIdSetWithin(selection *goquery.Selection, depth int) ClassesIdSet {
 	val, exists := selection.Attr("id")
 	id := ""
 	if exists {
@@ -119,15 +228,15 @@ func buildClassesIdSet(selection *goquery.Selection) ClassesIdSet {
 	tagName := goquery.NodeName(selection)
 	parent := selection.Parent()
 	parentPath := ""
-	if parent.Length() > 0 {
-		parentSet := buildClassesIdSet(parent)
+	if parent.Length() > 0 && depth > 0 {
+		parentSet := buildClassesIdSetWithin(parent, depth-1)
 		if parentSet.TagName != "body" && parentSet.TagName != "html" {
 			parentPath = parentSet.ToPath()
 		}
-		if !strings.Contains(parentPath, ".") && !strings.Contains(parentPath, "#") {
+		if !strings.Contains(parentPath, ".") && !strings.Contains(parentPath, "#") && depth > 1 {
 			parent = parent.Parent()
 			if parent.Length() > 0 {
-				parentSet = buildClassesIdSet(parent)
+				parentSet = buildClassesIdSetWithin(parent, depth-2)
 				if parentSet.TagName != "body" && parentSet.TagName != "html" {
 					parentPath = parentSet.ToPath()
 				}
@@ -151,6 +260,8 @@ func (cs *ClassesIdSet) ToPath() string {
 type LinkItem struct {
 	Title string `json:"title"`
 	Uri   string `json:"uri"`
+	// TitleLength is the length in characters of a title cut to linkTitleMaxChars
+	TitleLength int `json:"titleLength,omitempty"`
 }
 
 func uriIsInLinkItems(links []LinkItem, str string) bool {
@@ -171,8 +282,8 @@ func makePage(title string, uri string, exists bool, articles []Article, links [
 }
 
 func emptyPage() Page {
-	var articles []Article
-	var links []LinkItem
+	articles := []Article{}
+	links := []LinkItem{}
 	return Page{Title: "", Uri: "", Exists: false, Articles: articles, Links: links, Cached: false}
 }
 
@@ -180,109 +291,482 @@ func main() {
 	handleRequests()
 }
 
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// requestScheme reads the optional scheme path segment and rejects anything but http and https
+func requestScheme(w http.ResponseWriter, vars map[string]string) (string, bool) {
+	scheme := effectiveScheme(vars["scheme"])
+	if !isValidScheme(scheme) {
+		writeError(w, http.StatusBadRequest, "unsupported scheme: "+scheme)
+		return scheme, false
+	}
+	return scheme, true
+}
+
+// normalizePath decodes the url path segment and drops trailing slashes,
+// so example.com/post and example.com/post/ share one cache entry and upstream URL
+func normalizePath(path string) string {
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+	return strings.TrimRight(strings.TrimSpace(path), "/")
+}
+
+// isCacheBypass checks for the bypass or nocache modes, which read the page live without
+// reading or writing the cache, unlike refresh, which stores the live page
+func isCacheBypass(cacheMode string) bool {
+	return strings.EqualFold(cacheMode, "bypass") || strings.EqualFold(cacheMode, "nocache")
+}
+
 func homePage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid || !validDateRange(w, r) {
+		return
+	}
+	opts := fetchOptionsFromRequest(r)
+	if isCacheBypass(vars["cacheMode"]) {
+		page := readLiveBlogPageWithRetry(r.Context(), scheme+"://"+normalizePath(vars["url"]), opts)
+		w.Header().Set("cached", "bypass")
+		w.Header().Set("Cache-Control", "no-store")
+		writeBlogPage(w, r, page, opts)
+		return
+	}
 	useCache := vars["cacheMode"] != "refresh"
-	page, isCached := readBlogPage(vars["url"], vars["scheme"], useCache)
+	page, isCached, maxAge := readBlogPage(r.Context(), vars["url"], scheme, useCache, opts)
 	cacheType := "-"
 	if isCached {
-		cacheType = "redis"
+		cacheType = cacheName(cacheStore())
 	}
 	w.Header().Set("cached", cacheType)
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	json.NewEncoder(w).Encode(page)
+	if opts.isPrivate() {
+		w.Header().Set("Cache-Control", "no-store")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int64(maxAge.Seconds())))
+	}
+	writeBlogPage(w, r, page, opts)
+}
+
+// writeBlogPage applies the response only options to a page, whether cached or live
+func writeBlogPage(w http.ResponseWriter, r *http.Request, page Page, opts FetchOptions) {
+	if len(page.Timings) > 0 {
+		w.Header().Set("Server-Timing", serverTimingHeader(page.Timings))
+	}
+	// timing is kept as an alias of timings
+	if (queryBool(r, "timings") || queryBool(r, "timing")) && len(page.Timings) > 0 {
+		page.Timing = summarizeTimings(page.Timings)
+	} else {
+		page.Timings = nil
+	}
+	if !queryBool(r, "headers") {
+		page.Headers = nil
+	}
+	if !queryBool(r, "altText") {
+		page.AltTexts = nil
+	}
+	page.Articles = filterArticlesByDate(page.Articles, opts.Extract.Published)
+	page.requireLanguage(opts.Extract.RequireLang)
+	offset, limit := queryInt(r, "offset"), queryInt(r, "limit")
+	if page.window(offset, limit) {
+		setNextLink(w, r, offset, limit)
+	}
+	page.limitLinks(opts.Extract.MaxLinks)
+	writeJson(w, r, page)
 }
 
 func infoJson(w http.ResponseWriter, r *http.Request) {
-	routes := [2]string{"/", "/blog/:uri/:scheme/:cacheMode"}
+	routes := []string{"/", "/blog/:uri/:scheme/:cacheMode", "/blog/:uri/:cacheMode", "/discover/:uri/:scheme", "/discover/:uri", "/images/:uri/:scheme", "/images/:uri", "/head/:uri/:scheme", "/head/:uri", "/expand/:uri/:scheme", "/expand/:uri", "/tables/:uri/:scheme", "/tables/:uri", "/history/:uri/:scheme", "/history/:uri", "/crawl/:uri/:scheme", "/crawl/:uri", "/count/:uri/:scheme", "/count/:uri", "/outline/:uri/:scheme", "/outline/:uri", "/diff/:uri/:scheme", "/diff/:uri", "/sitemap/:uri/:scheme", "/sitemap/:uri", "/checklinks/:uri/:scheme", "/checklinks/:uri", "POST /batch", "/ws/batch", "GET /cache", "DELETE /cache", "/openapi.json", "/metrics", "/selftest"}
 	data := map[string]interface{}{
 		"title":  "Welcome",
 		"routes": routes,
 	}
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	json.NewEncoder(w).Encode(data)
+	writeJson(w, r, data)
 }
 
-func handleRequests() {
-	myRouter := mux.NewRouter().StrictSlash(true)
+func newRouter() *mux.Router {
+	// match on the encoded path so the url segment may carry a page path as %2F-escaped slashes
+	myRouter := mux.NewRouter().StrictSlash(true).UseEncodedPath()
+	myRouter.Use(requestIdMiddleware)
 	myRouter.HandleFunc("/", infoJson)
 	myRouter.HandleFunc("/info", infoJson)
 	myRouter.HandleFunc("/blog/{url}/{scheme}/{cacheMode}", homePage)
+	myRouter.HandleFunc("/blog/{url}/{cacheMode}", homePage)
 	myRouter.HandleFunc("/discover/{url}/{scheme}", discoverPage)
-	log.Fatal(http.ListenAndServe(":3756", myRouter))
+	myRouter.HandleFunc("/discover/{url}", discoverPage)
+	myRouter.HandleFunc("/images/{url}/{scheme}", imagesPage)
+	myRouter.HandleFunc("/images/{url}", imagesPage)
+	myRouter.HandleFunc("/head/{url}/{scheme}", headPage)
+	myRouter.HandleFunc("/head/{url}", headPage)
+	myRouter.HandleFunc("/expand/{url}/{scheme}", expandPage)
+	myRouter.HandleFunc("/expand/{url}", expandPage)
+	myRouter.HandleFunc("/tables/{url}/{scheme}", tablesPage)
+	myRouter.HandleFunc("/tables/{url}", tablesPage)
+	myRouter.HandleFunc("/history/{url}/{scheme}", historyPage)
+	myRouter.HandleFunc("/history/{url}", historyPage)
+	myRouter.HandleFunc("/crawl/{url}/{scheme}", crawlJson)
+	myRouter.HandleFunc("/crawl/{url}", crawlJson)
+	myRouter.HandleFunc("/count/{url}/{scheme}", countPage)
+	myRouter.HandleFunc("/count/{url}", countPage)
+	myRouter.HandleFunc("/outline/{url}/{scheme}", outlinePage)
+	myRouter.HandleFunc("/outline/{url}", outlinePage)
+	myRouter.HandleFunc("/diff/{url}/{scheme}", diffJson)
+	myRouter.HandleFunc("/diff/{url}", diffJson)
+	myRouter.HandleFunc("/sitemap/{url}/{scheme}", siteMapJson)
+	myRouter.HandleFunc("/sitemap/{url}", siteMapJson)
+	myRouter.HandleFunc("/checklinks/{url}/{scheme}", checkLinksJson)
+	myRouter.HandleFunc("/checklinks/{url}", checkLinksJson)
+	myRouter.HandleFunc("/batch", batchJson).Methods(http.MethodPost)
+	myRouter.HandleFunc("/ws/batch", batchSocket)
+	myRouter.HandleFunc("/cache", requireApiKey(listCacheJson)).Methods(http.MethodGet)
+	myRouter.HandleFunc("/cache", requireApiKey(purgeCacheJson)).Methods(http.MethodDelete)
+	myRouter.HandleFunc("/openapi.json", openApiJson)
+	myRouter.HandleFunc("/metrics", metricsPage)
+	myRouter.HandleFunc("/selftest", selfTestJson)
+	return myRouter
+}
+
+func handleRequests() {
+	log.Fatal(http.ListenAndServe(":3756", newRouter()))
+}
+
+func redisContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, redisTimeout)
 }
 
-func storeClient() *redis.Client {
-	return redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379",
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
+func pageCacheKey(path string) string {
+	return cachePrefix + cacheKeyUri(path)
 }
 
-func setCache(key string, data interface{}, minutes int64) bool {
-	var ctx = context.Background()
-	rdb := storeClient()
+// statsCacheKey namespaces keys for stats and job records apart from cached pages
+func statsCacheKey(name string) string {
+	return statsCachePrefix + name
+}
+
+func setCache(parent context.Context, key string, data interface{}, minutes int64) bool {
+	ctx, cancel := redisContext(parent)
+	defer cancel()
 	duration := time.Duration(minutes) * time.Minute
-	ret, err := json.MarshalIndent(data, "", " ")
-	rdb.Set(ctx, key, ret, duration)
-	return err == nil
+	ret, err := encodeCacheValue(data)
+	if err != nil {
+		return false
+	}
+	return cacheStore().Set(ctx, key, ret, duration) == nil
+}
+
+// touchCache resets the lifetime of a cached entry without rewriting it
+func touchCache(parent context.Context, key string, minutes int64) bool {
+	ctx, cancel := redisContext(parent)
+	defer cancel()
+	return cacheStore().Expire(ctx, key, time.Duration(minutes)*time.Minute) == nil
 }
 
-func getCache(key string) (result interface{}, errVal error) {
-	var ctx = context.Background()
-	rdb := storeClient()
+// getCacheTtl returns the remaining lifetime of a cached entry
+func getCacheTtl(parent context.Context, key string) (time.Duration, error) {
+	ctx, cancel := redisContext(parent)
+	defer cancel()
+	ttls, err := cacheStore().TTL(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return ttls[0], nil
+}
+
+func getCache(parent context.Context, key string) (result interface{}, errVal error) {
+	ctx, cancel := redisContext(parent)
+	defer cancel()
 	var page = emptyPage()
-	val, err := rdb.Get(ctx, key).Result()
+	val, err := cacheStore().Get(ctx, key)
 	if err == nil {
-		json.Unmarshal([]byte(val), &page)
+		err = decodeCacheValue(val, &page)
 	}
 	result = page
 	errVal = err
 	return
 }
 
-func readBlogPage(path string, scheme string, cached bool) (page Page, isCached bool) {
-	uri := scheme + "://" + path
-	cacheKey := "page:" + path
-	result, errVal := getCache(cacheKey)
+// readBlogPage serves the page from the cache when allowed, along with how long the result stays fresh
+func readBlogPage(ctx context.Context, path string, scheme string, cached bool, opts FetchOptions) (page Page, isCached bool, maxAge time.Duration) {
+	path = normalizePath(path)
+	uri := effectiveScheme(scheme) + "://" + path
+	cacheKey := pageCacheKey(path) + opts.cacheSuffix()
+	policy := cachePolicyFor("blog")
+	if opts.isPrivate() {
+		page = readLiveBlogPageWithRetry(ctx, uri, opts)
+		return
+	}
+	if !policy.Enabled {
+		page = readLiveBlogPage(ctx, uri, opts)
+		if err := recordSnapshot(ctx, path, page); err != nil {
+			opts.logger().Printf("history write failed path=%s error=%q", path, err.Error())
+		}
+		return
+	}
+	result, errVal := getCache(ctx, cacheKey)
+	if errVal != nil && errVal != errCacheMiss {
+		opts.logger().Printf("cache read failed key=%s error=%q", cacheKey, errVal.Error())
+	}
 	if errVal == nil && cached {
+		opts.logger().Printf("cache hit key=%s", cacheKey)
 		page = result.(Page)
 		page.setCached()
+		page.ensureSlices()
 		isCached = true
+		maxAge, _ = getCacheTtl(ctx, cacheKey)
+		if maxAge < 0 {
+			maxAge = 0
+		}
 		return
 	} else {
-		data := readLiveBlogPage(uri)
-		setCache(cacheKey, data, 1440)
+		if errVal == nil {
+			opts.Validators = conditionalHeaders(result.(Page).Headers)
+		}
+		data := readLiveBlogPageWithRetry(ctx, uri, opts)
+		if data.notModified {
+			opts.logger().Printf("not modified key=%s", cacheKey)
+			if !touchCache(ctx, cacheKey, int64(policy.TtlMinutes)) {
+				opts.logger().Printf("cache refresh failed key=%s", cacheKey)
+			}
+			page = result.(Page)
+			page.setCached()
+			page.ensureSlices()
+			isCached = true
+			maxAge = time.Duration(policy.TtlMinutes) * time.Minute
+			return
+		}
+		// timings only describe this fetch, so they are not cached
+		cachedData := data
+		cachedData.Timings = nil
+		// failed fetches, such as a timeout, and truncated pages are incomplete,
+		// so the next request should read them again
+		if isCacheablePage(data) && !setCache(ctx, cacheKey, cachedData, int64(policy.TtlMinutes)) {
+			opts.logger().Printf("cache write failed key=%s", cacheKey)
+		}
+		if err := recordSnapshot(ctx, path, data); err != nil {
+			opts.logger().Printf("history write failed path=%s error=%q", path, err.Error())
+		}
 		page = data
 		isCached = false
+		maxAge = time.Duration(policy.TtlMinutes) * time.Minute
 		return
 	}
 }
 
-func readLiveBlogPage(uri string) Page {
-	bow := surf.NewBrowser()
-	err := bow.Open(uri)
+// isCacheablePage accepts pages read in full, leaving out failed fetches, pages that do not
+// exist and pages whose extraction stopped at the deadline
+func isCacheablePage(page Page) bool {
+	return page.Exists && len(page.Error) < 1 && !page.Truncated
+}
+
+// readLiveBlogPageWithRetry fetches the page a second time after retryEmptyDelay when the
+// retryEmpty option is set and a page that exists yielded no articles, as CDN edges
+// sometimes serve a near empty page on a cache miss
+func readLiveBlogPageWithRetry(ctx context.Context, uri string, opts FetchOptions) Page {
+	page := readLiveBlogPage(ctx, uri, opts)
+	if !opts.Extract.RetryEmpty || !page.Exists || page.notModified || hasExtractedArticles(page.Articles) {
+		return page
+	}
+	opts.logger().Printf("retrying empty page uri=%s", uri)
+	select {
+	case <-time.After(retryEmptyDelay):
+	case <-ctx.Done():
+		return page
+	}
+	retried := readLiveBlogPage(ctx, uri, opts)
+	if !retried.Exists {
+		return page
+	}
+	retried.Warnings = append(retried.Warnings, "retried after the first fetch found no articles")
+	return retried
+}
+
+func readLiveBlogPage(ctx context.Context, uri string, opts FetchOptions) Page {
+	ctx, cancel := withTimeout(ctx, fetchTimeout)
+	defer cancel()
+	timer := newPhaseTimer()
+	var bow *browser.Browser
+	var err error
+	rendered := false
+	if renderEnabled(opts.Extract) && opts.Extract.RenderAlways {
+		// pages built by scripts may not even load without them, so they are only read rendered
+		bow, err = renderPage(ctx, uri, opts)
+		rendered = err == nil
+		timer.mark("render")
+	} else {
+		bow, err = fetchPage(ctx, uri, opts)
+		// the browser builds the document while reading the response, so this includes the HTML parse
+		timer.mark("fetch")
+		if err == nil && bow.StatusCode() == http.StatusNotModified && len(opts.Validators) > 0 {
+			return Page{Uri: uri, Exists: true, notModified: true}
+		}
+		if err == nil && renderEnabled(opts.Extract) && countWordsNotInLinks(bow) < renderMinWords {
+			if renderedBow, renderErr := renderPage(ctx, uri, opts); renderErr == nil {
+				bow = renderedBow
+				rendered = true
+			}
+			timer.mark("render")
+		}
+	}
 	exists := err == nil
 	title := ""
-	var links []LinkItem
-	var articles []Article
+	titleSource := ""
+	links := []LinkItem{}
+	articles := []Article{}
+	emails := []string{}
+	socials := []LinkItem{}
+	warnings := []string{}
+	wordCount := 0
+	hash := ""
+	thinContent := false
+	softError := false
+	truncated := false
+	media := []MediaItem{}
+	microdata := []MicrodataItem{}
+	confidence := 0.0
+	commentCount := 0
+	var recipe *Recipe
+	var product *Product
+	var shareCount *int
+	altTexts := []string{}
+	missingAlt := 0
+	comments := []Comment{}
+	var linkGroups map[string][]LinkItem
+	lang := ""
+	langCertain := false
+	contentWords := 0
+	if exists {
+		prepareNoscript(bow, opts.Extract)
+		bodyText := readBodyText(bow)
+		wordCount = len(strings.Fields(bodyText))
+		hash = contentHash(bodyText)
+		lang, langCertain = readLanguage(bow, bodyText)
+		contentWords = countWordsNotInLinks(bow)
+		thinContent = contentWords < thinContentWords
+		// pages served with 200 but too short to hold content, such as soft 404s, are not treated as existing
+		softError = opts.Extract.MinWords > 0 && contentWords < opts.Extract.MinWords
+		exists = !softError
+	}
+	if softError {
+		// nothing is extracted from a soft 404 but its title
+		title, titleSource = pageTitle(bow, uri)
+	}
 	if exists {
-		articles = readBlogArticles(bow)
+		emails = extractEmails(bow)
+		media = readMedia(bow)
+		microdata = readMicrodata(bow)
+		recipe, product = readStructuredData(bow, microdata)
+		altTexts, missingAlt = readAltTexts(bow.Find("body"))
+		if opts.Extract.GroupLinks {
+			// grouped before comment threads are taken out of the document
+			linkGroups = groupLinks(bow)
+		}
+		commentCount, comments = readComments(bow, opts.Extract.Comments)
+		signals := confidenceSignals{
+			ArticleTags:    bow.Find("article").Length() > 0,
+			Words:          wordCount,
+			ContentWords:   contentWords,
+			StructuredData: len(microdata) > 0 || bow.Find("script[type='application/ld+json']").Length() > 0,
+		}
+		timer.mark("parse")
+		if opts.Extract.Readable {
+			if article, found := readReadableArticle(bow); found {
+				articles = []Article{article}
+			} else {
+				warnings = append(warnings, "no readable content found")
+			}
+		} else {
+			articles, warnings, truncated = readBlogArticles(ctx, bow, opts.Extract)
+		}
+		if !hasExtractedArticles(articles) && opts.Extract.SegmentHeadings {
+			articles = readHeadingSections(bow)
+		}
+		if opts.Extract.MergePages && len(articles) == 1 && len(articles[0].Content) > 0 {
+			warnings = append(warnings, mergeContentPages(ctx, bow, &articles[0], opts)...)
+		}
+		if opts.Extract.Sanitize {
+			sanitizeArticles(articles)
+		}
+		truncateArticles(articles, opts.Extract.MaxContentBytes, opts.Extract.MaxContentChars)
+		// the counts shown on a listing belong to its posts, so page counts are only read for a single post.
+		// A shown comment count stands in when the comment thread is loaded by a script
+		if len(articles) <= 1 {
+			if shown := readDisplayCount(bow.Find("body"), commentCountSelectors); commentCount < 1 && shown != nil {
+				commentCount = *shown
+			}
+			shareCount = readDisplayCount(bow.Find("body"), shareCountSelectors)
+		}
+		signals.Articles = len(articles)
+		signals.Warnings = len(warnings)
+		confidence = extractionConfidence(signals)
+		timer.mark("articles")
 		linkObjs := bow.Links()
-		title = bow.Title()
+		socials = extractSocials(linkObjs)
+		title, titleSource = pageTitle(bow, uri)
 		for i := 0; i < len(linkObjs); i++ {
 			linkRef := linkObjs[i]
 			path := linkRef.Url().Path
 			if len(path) > 0 {
-				newLink := LinkItem{Uri: path, Title: linkRef.Text}
+				newLink := makeLinkItem(path, linkRef.Text)
 				if !uriIsInLinkItems(links, path) {
 					links = append(links, newLink)
 				}
 			}
 		}
+		timer.mark("links")
+	}
+	page := makePage(title, uri, exists, articles, links)
+	page.Emails = emails
+	page.Socials = socials
+	page.Warnings = warnings
+	page.FetchedAt = time.Now().UTC().Format(time.RFC3339)
+	page.WordCount = wordCount
+	page.ContentHash = hash
+	page.ThinContent = thinContent
+	page.SoftError = softError
+	page.Truncated = truncated
+	page.Media = media
+	page.Microdata = microdata
+	page.Confidence = confidence
+	page.Rendered = rendered
+	page.TitleSource = titleSource
+	page.Recipe = recipe
+	page.Product = product
+	page.ShareCount = shareCount
+	page.ImagesMissingAlt = missingAlt
+	page.AltTexts = altTexts
+	page.CommentCount = commentCount
+	page.Lang = lang
+	page.LinkGroups = linkGroups
+	page.LangUncertain = !langCertain && exists
+	if opts.Extract.Comments {
+		page.Comments = comments
+	}
+	page.Timings = timer.phases
+	page.ensureSlices()
+	if err != nil {
+		page.Error = fetchErrorMessage(err)
+	} else {
+		readVariants(bow, &page)
+		page.Direction = readDirection(bow, opts.language())
+		page.Headers = upstreamHeaders(bow.ResponseHeaders())
 	}
-	return makePage(title, uri, exists, articles, links)
+	return page
+}
+
+// readBodyText returns the visible body text, leaving the document untouched
+func readBodyText(bow *browser.Browser) string {
+	body := bow.Find("body").Clone()
+	body.Find("script,style,noscript,template").Remove()
+	return normalizedText(body)
+}
+
+// contentHash fingerprints text with whitespace differences normalised away
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join(strings.Fields(text), " ")))
+	return hex.EncodeToString(sum[:])
 }
 
 func removeSpaces(text string) string {
@@ -316,31 +800,85 @@ func extractNumWords(selection *goquery.Selection) int {
 
 func discoverPage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	url := vars["scheme"] + "://" + vars["url"]
-	ps := discoverLivePage(url)
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	json.NewEncoder(w).Encode(ps)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	opts := fetchOptionsFromRequest(r)
+	var ps PageStats
+	if !readEndpointCache(r.Context(), "discover", url, &ps, opts) {
+		ps = discoverLivePage(r.Context(), url, opts)
+		if ps.Exists {
+			writeEndpointCache(r.Context(), "discover", url, ps, opts)
+		}
+	}
+	if !queryBool(r, "altText") {
+		ps.AltTexts = nil
+	}
+	writeJson(w, r, ps)
+}
+
+// media elements counted by discoverLivePage before they are stripped, as count key and selector
+var mediaCountTags = [][2]string{
+	{"images", "img"},
+	{"figures", "figure"},
+	{"iframes", "iframe"},
+	{"videos", "video"},
+	{"audios", "audio"},
+	{"objects", "object"},
+	{"svgs", "svg"},
+}
+
+const strippedMediaTags = "img,figure,object,iframe,svg,audio,video,script,style"
+
+// countWordsNotInLinks counts the words of a copy of the body without media or link text,
+// as reported by discover and used to flag thin content
+func countWordsNotInLinks(bow *browser.Browser) int {
+	body := bow.Find("body").Clone()
+	body.Find(strippedMediaTags).Remove()
+	body.Find("a").Remove()
+	return extractNumWords(body)
 }
 
-func discoverLivePage(uri string) PageStats {
-	bow := surf.NewBrowser()
-	err := bow.Open(uri)
+func discoverLivePage(ctx context.Context, uri string, opts FetchOptions) PageStats {
+	ctx, cancel := withTimeout(ctx, discoverTimeout)
+	defer cancel()
+	bow, err := fetchPage(ctx, uri, opts)
 	exists := err == nil
 
 	ps := newPageStats(uri, exists)
+	if err != nil {
+		ps.Error = fetchErrorMessage(err)
+	}
 	if exists {
+		prepareNoscript(bow, opts.Extract)
 		ps.addCountItem("links", len(bow.Links()))
+		internalLinks, externalLinks := countLinkDestinations(bow)
+		ps.addCountItem("internalLinks", internalLinks)
+		ps.addCountItem("externalLinks", externalLinks)
+		ps.addCountItem("externalLinksPercent", percentOf(externalLinks, internalLinks+externalLinks))
 		ps.addCountItem("articleTags", bow.Find("article").Length())
 		ps.addCountItem("sectionTags", bow.Find("section").Length())
 		ps.addCountItem("tableTags", bow.Find("table").Length())
 		body := bow.Find("body")
-		body.Find("img,figure,object,iframe,svg,audio,video,script,style").Remove()
+		for _, media := range mediaCountTags {
+			ps.addCountItem(media[0], body.Find(media[1]).Length())
+		}
+		altTexts, missingAlt := readAltTexts(body)
+		ps.addCountItem("imagesMissingAlt", missingAlt)
+		ps.AltTexts = altTexts
+		body.Find(strippedMediaTags).Remove()
 		bodyWords := extractWords(body)
 		ps.addCountItem("words", len(bodyWords))
+		if len(opts.Extract.Keyword) > 0 {
+			ps.addKeywordCounts(bodyWords, opts.Extract.Keyword)
+		}
 		ps.addCountItem("numInnerLinks", body.Find("a").Length())
+		ps.addCountItem("wordsNotInLinks", countWordsNotInLinks(bow))
 		body.Find("a").Remove()
-		ps.addCountItem("wordsNotInLinks", extractNumWords(body))
-		tags := body.Find("div, article, section, aside")
+		ps.addCountItem("contentWords", countContentWords(body))
+		tags := body.Find(opts.Extract.blockTagSelector())
 		/* for i := 0; i < tags.Length(); i++ {
 			if hasTextNodes(tags.Eq(i)) {
 				currEl := tags.Eq(i).Clone()
@@ -355,50 +893,217 @@ func discoverLivePage(uri string) PageStats {
 			cData := buildClassesIdSet(tags.Eq(i))
 			if cData.WordCount > 16 {
 				ps.addCountItem(cData.ToPath(), cData.WordCount)
+				ps.addSuggestion(tags.Eq(i), cData)
 			}
 		}
-		ps.setWords(bodyWords)
+		ps.setWords(bodyWords, stopwordsFor(opts.language()))
 	}
 	return ps
 }
 
-func readBlogArticles(bow *browser.Browser) []Article {
-	var articles = bow.Find("article")
-	const maxNum = 100
-	p1 := regexp.MustCompile(`<!--[^>]*?-->`)
-	articles.Find("img,svg,embed,iframe,object,style,script").Remove()
+// countContentWords estimates the length of the real content, leaving out the words of
+// boilerplate elements such as sidebars and related posts
+func countContentWords(selection *goquery.Selection) int {
+	content := selection.Clone()
+	content.Find(strings.Join(boilerplateSelectors, ",")).Remove()
+	return extractNumWords(content)
+}
+
+const maxNum = 100
+
+// articles with fewer words than this are reported as suspiciously short
+const minArticleWords = 20
+
+func isPlausibleArticleCount(num int) bool {
+	return num > 0 && num <= maxNum
+}
+
+// findArticleElements tries each container selector in turn and keeps the first with a plausible match count
+func findArticleElements(bow *browser.Browser) *goquery.Selection {
+	for i := 0; i < len(articleSelectors); i++ {
+		sel := bow.Find(articleSelectors[i])
+		if isPlausibleArticleCount(sel.Length()) {
+			return sel
+		}
+	}
+	return bow.Find("article").Slice(0, 0)
+}
+
+// embedded and script elements dropped from article content
+const articleStrippedTags = "img,svg,embed,iframe,object,style,script"
+
+var htmlCommentRgx = regexp.MustCompile(`<!--[^>]*?-->`)
+
+// articleContent returns the inner html of an article without comments
+func articleContent(article *goquery.Selection) (string, error) {
+	itemHtml, err := article.Html()
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(htmlCommentRgx.ReplaceAllString(itemHtml, ""), "\n\t "), nil
+}
+
+// findArticleTitle tries the requested title selectors in priority order before the first h1-h3 heading
+func findArticleTitle(article *goquery.Selection, titleSelectors []string) *goquery.Selection {
+	for i := 0; i < len(titleSelectors); i++ {
+		titleEls := article.Find(titleSelectors[i])
+		for j := 0; j < titleEls.Length(); j++ {
+			if len(strings.TrimSpace(titleEls.Eq(j).Text())) > 0 {
+				return titleEls.Eq(j)
+			}
+		}
+	}
+	return article.Find("h1,h2,h3").First()
+}
+
+// findTitleLink returns the title element itself when it is a link, otherwise the links within it
+func findTitleLink(titleElement *goquery.Selection) *goquery.Selection {
+	if goquery.NodeName(titleElement) == "a" {
+		return titleElement
+	}
+	return titleElement.Find("a")
+}
+
+// extractExcerpt prefers the page og:description when the page holds a single article,
+// then an excerpt or summary element, and finally the first words of the article text after its headings
+func extractExcerpt(article *goquery.Selection, pageDescription string, numArticles int) string {
+	if numArticles == 1 && len(strings.TrimSpace(pageDescription)) > 0 {
+		return removeSpaces(strings.TrimSpace(pageDescription))
+	}
+	summary := article.Find(".excerpt,.summary").First()
+	if text := removeSpaces(strings.TrimSpace(summary.Text())); len(text) > 0 {
+		return text
+	}
+	text := article.Clone()
+	text.Find("h1,h2,h3,h4,h5,h6").Remove()
+	words := strings.Fields(text.Text())
+	if len(words) > excerptWords {
+		return strings.Join(words[:excerptWords], " ") + "…"
+	}
+	return strings.Join(words, " ")
+}
+
+// articleLinks lists the distinct hrefs within an article as written in the page
+func articleLinks(article *goquery.Selection) []LinkItem {
+	linkEls := article.Find("a")
+	numLinks := linkEls.Length()
+	links := []LinkItem{}
+	for j := 0; j < numLinks; j++ {
+		val, exists := linkEls.Eq(j).Attr("href")
+		if exists {
+			lk := makeLinkItem(val, linkEls.Eq(j).Text())
+			if !uriIsInLinkItems(links, val) {
+				links = append(links, lk)
+			}
+		}
+	}
+	return links
+}
+
+// readBlogArticles returns the extracted articles along with non-fatal extraction warnings.
+// With the partial option the deadline of ctx is checked between articles, and the articles
+// extracted so far are returned flagged as truncated once it has passed
+func readBlogArticles(ctx context.Context, bow *browser.Browser, opts ExtractOptions) ([]Article, []string, bool) {
+	var articles = findArticleElements(bow)
+	warnings := []string{}
 	numArticles := articles.Length()
-	var output [maxNum]Article
+	pageMarked := hasPaywallMarkers(bow)
+	// embeds are read before iframes are stripped from the content
+	videos := make([][]string, numArticles)
 	for i := 0; i < numArticles; i++ {
+		videos[i] = readEmbeddedVideos(bow, articles.Eq(i))
+	}
+	articles.Find(articleStrippedTags).Remove()
+	if numArticles < 1 {
+		warnings = append(warnings, "no article tags found")
+	}
+	pageDescription := bow.Find("meta[property='og:description']").First().AttrOr("content", "")
+	// only articles with a linked title are returned, in document order
+	output := []Article{}
+	hashes := []string{}
+	truncated := false
+	for i := 0; i < numArticles; i++ {
+		if opts.Partial && ctx.Err() != nil {
+			truncated = true
+			warnings = append(warnings, fmt.Sprintf("extraction stopped after %d articles at the deadline", i))
+			break
+		}
 		if i < maxNum {
 
-			itemHtml, itemErr := articles.Eq(i).Html()
+			content, itemErr := articleContent(articles.Eq(i))
 			if itemErr == nil {
-				content := strings.Trim(p1.ReplaceAllString(itemHtml, ""), "\n\t ")
-				titleEls := articles.Eq(i).Find("h1,h2,h3")
-				if titleEls.Length() > 0 {
-					titleElement := titleEls.First()
+				if extractNumWords(articles.Eq(i)) < minArticleWords {
+					warnings = append(warnings, fmt.Sprintf("article %d content looks too short", i+1))
+				}
+				titleElement := findArticleTitle(articles.Eq(i), opts.TitleSelectors)
+				if titleElement.Length() > 0 {
 					title := titleElement.Text()
-					linkEl := titleElement.Find("a")
-					if linkEl.Length() > 0 {
+					linkEl := findTitleLink(titleElement)
+					if !opts.isLongEnoughTitle(title) {
+						warnings = append(warnings, fmt.Sprintf("article %d title was too short", i+1))
+					} else if linkEl.Length() > 0 {
 						uri := linkEl.AttrOr("href", "")
-						linkEls := articles.Eq(i).Find("a")
-						numLinks := linkEls.Length()
-						var links []LinkItem
-						for j := 0; j < numLinks; j++ {
-							val, exists := linkEls.Eq(j).Attr("href")
-							if exists {
-								lk := LinkItem{Uri: val, Title: linkEls.Eq(j).Text()}
-								if !uriIsInLinkItems(links, val) {
-									links = append(links, lk)
-								}
+						article := makeArticle(title, uri, content, articleLinks(articles.Eq(i)))
+						article.Excerpt = extractExcerpt(articles.Eq(i), pageDescription, numArticles)
+						article.Videos = videos[i]
+						article.Published = articlePublished(articles.Eq(i), bow, numArticles)
+						article.CommentCount = readDisplayCount(articles.Eq(i), commentCountSelectors)
+						article.ShareCount = readDisplayCount(articles.Eq(i), shareCountSelectors)
+						// a paywalled teaser is summarised by the page description when there is one
+						if isPaywalledArticle(articles.Eq(i), pageMarked, pageDescription, numArticles) {
+							article.Paywalled = true
+							if len(strings.TrimSpace(pageDescription)) > 0 {
+								article.Excerpt = removeSpaces(strings.TrimSpace(pageDescription))
 							}
 						}
-						output[i] = makeArticle(title, uri, content, links)
+						output = append(output, article)
+						hashes = append(hashes, contentHash(articles.Eq(i).Text()))
+					} else {
+						warnings = append(warnings, fmt.Sprintf("article %d title had no link", i+1))
 					}
+				} else {
+					warnings = append(warnings, fmt.Sprintf("article %d had no title", i+1))
 				}
 			}
 		}
 	}
-	return output[0:numArticles]
+	if opts.Dedupe {
+		return dedupeArticles(output, hashes), warnings, truncated
+	}
+	return output, warnings, truncated
+}
+
+// dedupeKey identifies an article by its permalink, without fragment or trailing slash. Links to
+// the site root or to a fragment of the page are shared by unrelated teasers, so they give no key
+func dedupeKey(article Article) string {
+	uri := strings.TrimSpace(strings.SplitN(article.Uri, "#", 2)[0])
+	if parsed, err := url.Parse(uri); err != nil || (strings.Trim(parsed.Path, "/") == "" && len(parsed.RawQuery) < 1) {
+		return ""
+	}
+	return strings.TrimRight(uri, "/")
+}
+
+// dedupeArticles keeps the first of the articles sharing a permalink or a content hash, such as
+// a post shown both in a featured block and in the main list
+func dedupeArticles(articles []Article, hashes []string) []Article {
+	unique := []Article{}
+	seen := map[string]bool{}
+	for i := 0; i < len(articles); i++ {
+		keys := []string{}
+		if key := dedupeKey(articles[i]); len(key) > 0 {
+			keys = append(keys, "uri:"+key)
+		}
+		if len(hashes[i]) > 0 {
+			keys = append(keys, "hash:"+hashes[i])
+		}
+		repeated := false
+		for j := 0; j < len(keys); j++ {
+			repeated = repeated || seen[keys[j]]
+			seen[keys[j]] = true
+		}
+		if !repeated {
+			unique = append(unique, articles[i])
+		}
+	}
+	return unique
 }
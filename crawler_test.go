@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// withFetchTimeout shortens the blog fetch timeout until the test ends
+func withFetchTimeout(t *testing.T, timeout time.Duration) {
+	previous := fetchTimeout
+	fetchTimeout = timeout
+	t.Cleanup(func() {
+		fetchTimeout = previous
+	})
+}
+
+func TestFailedFetchesAreNotCached(t *testing.T) {
+	useMemoryCache(t)
+	withFetchTimeout(t, 50*time.Millisecond)
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<article><h2><a href="/post">Post</a></h2><p>Text.</p></article>`)
+	}))
+	defer server.Close()
+	path := server.Listener.Addr().String() + "/post"
+	page, _, _ := readBlogPage(context.Background(), path, "http", true, FetchOptions{})
+	if len(page.Error) < 1 {
+		t.Fatalf("expected the first fetch to time out")
+	}
+	page, isCached, _ := readBlogPage(context.Background(), path, "http", true, FetchOptions{})
+	if isCached || len(page.Error) > 0 || len(page.Articles) != 1 {
+		t.Errorf("expected a live read after the timeout, got cached=%v error=%q", isCached, page.Error)
+	}
+	if _, isCached, _ = readBlogPage(context.Background(), path, "http", true, FetchOptions{}); !isCached {
+		t.Errorf("expected the complete page to be cached")
+	}
+}
+
+func TestTrailingSlashFormsShareACacheEntry(t *testing.T) {
+	for _, path := range []string{"example.com/blog/", "example.com/blog//", " example.com/blog ", "example.com%2Fblog%2F"} {
+		if normalized := normalizePath(path); normalized != "example.com/blog" {
+			t.Errorf("expected %q to normalise to example.com/blog, got %q", path, normalized)
+		}
+	}
+	if pageCacheKey(normalizePath("example.com/blog/")) != pageCacheKey(normalizePath("example.com/blog")) {
+		t.Errorf("expected both forms to share a cache key")
+	}
+	useMemoryCache(t)
+	server := newRecordingServer(t)
+	host := server.Listener.Addr().String()
+	readBlogPage(context.Background(), host+"/post/", "http", true, FetchOptions{})
+	_, isCached, _ := readBlogPage(context.Background(), host+"/post", "http", true, FetchOptions{})
+	if !isCached || server.hits != 1 {
+		t.Errorf("expected the second form to be served from the cache, got cached=%v after %d fetches", isCached, server.hits)
+	}
+}
+
+const entryTitleLayout = `<html><body>
+<article><h3 class="cat">Featured</h3><div class="entry-title"><a href="/first-post">The first post</a></div><p>First text.</p></article>
+<article><h3 class="cat">News</h3><span class="entry-title"></span><p class="headline"><a href="/second-post">The second post</a></p><p>Second text.</p></article>
+</body></html>`
+
+func TestTitleSelectorsFindEntryTitles(t *testing.T) {
+	opts := extractOptionsFromRequest(httptest.NewRequest(http.MethodGet, "/?titleSelectors=.entry-title,.headline", nil))
+	articles, _, _ := readBlogArticles(context.Background(), openHtml(t, entryTitleLayout), opts)
+	if len(articles) != 2 || articles[0].Title != "The first post" || articles[1].Uri != "/second-post" {
+		t.Errorf("expected both entry titles, the empty one skipped, got %+v", articles)
+	}
+	if opts.cacheSuffix() == extractOptionsFromRequest(httptest.NewRequest(http.MethodGet, "/", nil)).cacheSuffix() {
+		t.Errorf("expected title selectors to split the cache key")
+	}
+	articles, warnings, _ := readBlogArticles(context.Background(), openHtml(t, entryTitleLayout), ExtractOptions{})
+	if hasExtractedArticles(articles) || !stringInList(warnings, "article 1 title had no link") {
+		t.Errorf("expected the unlinked category headings without selectors, got %+v and %v", articles, warnings)
+	}
+}
+
+func TestExcerptPrefersExplicitSummaries(t *testing.T) {
+	previous := excerptWords
+	excerptWords = 5
+	defer func() { excerptWords = previous }()
+	bow := openHtml(t, `<html><head><meta property="og:description" content="The page description."></head><body>
+<article><h2><a href="/one">One</a></h2><p class="excerpt">  The   hand written
+ summary. </p><p>The full text of the first post.</p></article>
+<article><h2><a href="/two">Two</a></h2>
+<p>The full text of the second post, long enough to be cut.</p></article>
+<article><h2><a href="/three">Three</a></h2><p>Short.</p></article>
+</body></html>`)
+	articles, _, _ := readBlogArticles(context.Background(), bow, ExtractOptions{})
+	expected := []string{"The hand written summary.", "The full text of the…", "Short."}
+	for i := 0; i < len(expected); i++ {
+		if i >= len(articles) || articles[i].Excerpt != expected[i] {
+			t.Fatalf("expected excerpts %q, got %+v", expected, articles)
+		}
+	}
+	single := openHtml(t, `<html><head><meta property="og:description" content="The page description."></head><body>
+<article><h1><a href="/one">One</a></h1><p class="summary">A summary.</p></article></body></html>`)
+	if articles, _, _ = readBlogArticles(context.Background(), single, ExtractOptions{}); articles[0].Excerpt != "The page description." {
+		t.Errorf("expected the page description for a single article, got %q", articles[0].Excerpt)
+	}
+}
+
+func TestEmptyPagesListEmptyArrays(t *testing.T) {
+	useMemoryCache(t)
+	server := serveHtml(t, `<html><body></body></html>`)
+	for _, mode := range []string{"cached", "cached", "bypass"} {
+		w := getRoute(blogRoute(server.URL+"/empty", mode))
+		body := w.Body.String()
+		for _, field := range []string{`"links":[]`, `"articles":[]`, `"emails":[]`, `"warnings":[`} {
+			if !strings.Contains(body, field) {
+				t.Errorf("expected %s in the %s response, got %s", field, mode, body)
+			}
+		}
+		if strings.Contains(body, "null") {
+			t.Errorf("expected no null lists in the %s response, got %s", mode, body)
+		}
+	}
+}
+
+const softNotFound = `<html><head><title>Not found</title></head><body>
+<nav><a href="/home">Home</a><a href="/blog">Blog</a></nav>
+<article><h2><a href="/missing">Oops</a></h2><p>Page not found.</p></article>
+<p>Contact hello@example.com</p>
+</body></html>`
+
+func TestThinPagesAreSoftErrors(t *testing.T) {
+	server := serveHtml(t, softNotFound)
+	page := readLiveBlogPage(context.Background(), server.URL+"/missing", FetchOptions{Extract: ExtractOptions{MinWords: 50}})
+	if page.Exists || !page.SoftError {
+		t.Fatalf("expected a soft error, got exists=%v softError=%v", page.Exists, page.SoftError)
+	}
+	if len(page.Articles) != 0 || len(page.Links) != 0 || len(page.Emails) != 0 {
+		t.Errorf("expected nothing extracted, got %d articles, %d links and %d emails", len(page.Articles), len(page.Links), len(page.Emails))
+	}
+	if page.Title != "Not found" {
+		t.Errorf("expected the title to be kept, got %q", page.Title)
+	}
+	page = readLiveBlogPage(context.Background(), server.URL+"/missing", FetchOptions{})
+	if !page.Exists || page.SoftError {
+		t.Errorf("expected no soft error without minWords, got exists=%v softError=%v", page.Exists, page.SoftError)
+	}
+}
+
+// deadlineAfter reports its deadline as passed once Err has been checked checks times,
+// standing in for an extraction slowed past its deadline after as many articles
+type deadlineAfter struct {
+	context.Context
+	checks int32
+}
+
+func (d *deadlineAfter) Err() error {
+	if atomic.AddInt32(&d.checks, -1) < 0 {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+const threeArticles = `<html><body>
+<article><h2><a href="/one">One</a></h2><p>First post.</p></article>
+<article><h2><a href="/two">Two</a></h2><p>Second post.</p></article>
+<article><h2><a href="/three">Three</a></h2><p>Third post.</p></article>
+</body></html>`
+
+func TestPartialExtractionKeepsArticlesReadBeforeTheDeadline(t *testing.T) {
+	bow := openHtml(t, threeArticles)
+	ctx := &deadlineAfter{Context: context.Background(), checks: 2}
+	articles, warnings, truncated := readBlogArticles(ctx, bow, ExtractOptions{Partial: true})
+	if !truncated || len(articles) != 2 || articles[1].Title != "Two" {
+		t.Fatalf("expected the first 2 articles and truncated, got %d articles, truncated=%v", len(articles), truncated)
+	}
+	if !stringInList(warnings, "extraction stopped after 2 articles at the deadline") {
+		t.Errorf("expected a deadline warning, got %v", warnings)
+	}
+	if isCacheablePage(Page{Exists: true, Truncated: true}) {
+		t.Errorf("expected a truncated page not to be cached")
+	}
+}
+
+func TestExtractionWithoutPartialIgnoresTheDeadline(t *testing.T) {
+	bow := openHtml(t, threeArticles)
+	ctx := &deadlineAfter{Context: context.Background(), checks: 0}
+	articles, _, truncated := readBlogArticles(ctx, bow, ExtractOptions{})
+	if truncated || len(articles) != 3 {
+		t.Errorf("expected all 3 articles, got %d, truncated=%v", len(articles), truncated)
+	}
+}
+
+func TestDedupeMergesPermalinkVariants(t *testing.T) {
+	bow := openHtml(t, `<html><body>
+<article><h2><a href="/post/">Post</a></h2><p>Featured teaser.</p></article>
+<article><h2><a href="/post#comments">Post</a></h2><p>The full list entry.</p></article>
+</body></html>`)
+	articles, _, _ := readBlogArticles(context.Background(), bow, ExtractOptions{Dedupe: true})
+	if len(articles) != 1 || articles[0].Excerpt == "The full list entry." {
+		t.Errorf("expected only the first of the permalink variants, got %+v", articles)
+	}
+}
+
+func TestDedupeKeepsTeasersLinkingToTheRootOrAFragment(t *testing.T) {
+	bow := openHtml(t, `<html><body>
+<article><h2><a href="/">Latest</a></h2><p>First teaser.</p></article>
+<article><h2><a href="/">Latest</a></h2><p>Second teaser.</p></article>
+<article><h2><a href="#">Latest</a></h2><p>Third teaser.</p></article>
+</body></html>`)
+	articles, _, _ := readBlogArticles(context.Background(), bow, ExtractOptions{Dedupe: true})
+	if len(articles) != 3 {
+		t.Errorf("expected all 3 teasers, got %d", len(articles))
+	}
+}
+
+func TestDedupeDropsTheSameTextUnderAnotherLink(t *testing.T) {
+	html := `<html><body>
+<article><h2><a href="/post">Post</a></h2><p>Same text.</p></article>
+<article><h2><a href="/?p=12">Post</a></h2><p>Same text.</p></article>
+</body></html>`
+	deduped, _, _ := readBlogArticles(context.Background(), openHtml(t, html), ExtractOptions{Dedupe: true})
+	raw, _, _ := readBlogArticles(context.Background(), openHtml(t, html), ExtractOptions{})
+	if len(deduped) != 1 || len(raw) != 2 {
+		t.Errorf("expected 1 article deduped and 2 raw, got %d and %d", len(deduped), len(raw))
+	}
+}
+
+const repeatedArticle = `<html><body>
+<article class="featured"><h2><a href="/">Welcome</a></h2> <p>The same   teaser text.</p></article>
+<article><h2><a href="/" class="title">Welcome</a></h2>
+<p>The same
+ teaser text.</p></article>
+<article><h2><a href="/">Welcome</a></h2><p>Another teaser.</p></article>
+</body></html>`
+
+func TestDedupeDropsRepeatedArticleText(t *testing.T) {
+	articles, _, _ := readBlogArticles(context.Background(), openHtml(t, repeatedArticle), ExtractOptions{Dedupe: true})
+	if len(articles) != 2 || !strings.Contains(articles[1].Content, "Another teaser.") {
+		t.Errorf("expected the repeated text dropped whatever its spacing, got %+v", articles)
+	}
+	if articles, _, _ = readBlogArticles(context.Background(), openHtml(t, repeatedArticle), ExtractOptions{}); len(articles) != 3 {
+		t.Errorf("expected every article without dedupe, got %d", len(articles))
+	}
+}
+
+const noscriptHeavy = `<html><head><noscript><link rel="stylesheet" href="/no-js.css"></noscript></head><body>
+<noscript><img src="https://tracker.example.com/pixel.gif" width="1" height="1"></noscript>
+<noscript><p>Please enable JavaScript to use this site, it works best with scripts turned on.</p></noscript>
+<div id="app"></div>
+<noscript><article><h2><a href="/static-post">Static post</a></h2><p>Server rendered fallback.</p></article></noscript>
+</body></html>`
+
+func TestNoscriptContentIsLeftOutByDefault(t *testing.T) {
+	server := serveHtml(t, noscriptHeavy)
+	page := readLiveBlogPage(context.Background(), server.URL+"/", FetchOptions{})
+	if len(page.Articles) != 0 || page.WordCount != 0 || uriIsInLinkItems(page.Links, "/static-post") {
+		t.Errorf("expected the noscript content to be ignored, got %d articles and %d words", len(page.Articles), page.WordCount)
+	}
+}
+
+func TestNoscriptContentIsReadOnRequest(t *testing.T) {
+	server := serveHtml(t, noscriptHeavy)
+	opts := extractOptionsFromRequest(httptest.NewRequest(http.MethodGet, "/?noscript=include", nil))
+	page := readLiveBlogPage(context.Background(), server.URL+"/", FetchOptions{Extract: opts})
+	if len(page.Articles) != 1 || page.Articles[0].Uri != "/static-post" {
+		t.Errorf("expected the noscript article to be read as markup, got %+v", page.Articles)
+	}
+	if strings.Contains(page.Articles[0].Content, "&lt;") || page.WordCount < 15 {
+		t.Errorf("expected the noscript markup to be parsed rather than read as text, got %d words", page.WordCount)
+	}
+}
+
+func TestUntitledArticlesLeaveNoPlaceholders(t *testing.T) {
+	server := serveHtml(t, `<html><body>
+<article><p>An untitled aside with nothing to link to.</p></article>
+<article><h2>A heading without a link</h2><p>Some text.</p></article>
+<article><h2><a href="/post">The real post</a></h2><p>Post text.</p></article>
+<article></article>
+</body></html>`)
+	w := getRoute(blogRoute(server.URL+"/", "refresh"))
+	var page Page
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatalf("expected a JSON page, got %s", w.Body.String())
+	}
+	if len(page.Articles) != 1 || page.Articles[0].Uri != "/post" {
+		t.Errorf("expected only the linked article, got %+v", page.Articles)
+	}
+	if strings.Contains(w.Body.String(), `{"title":""`) {
+		t.Errorf("expected no empty article in the response, got %s", w.Body.String())
+	}
+	if !stringInList(page.Warnings, "article 1 had no title") || !stringInList(page.Warnings, "article 2 title had no link") {
+		t.Errorf("expected the skipped articles to be reported, got %v", page.Warnings)
+	}
+}
+
+func TestBypassLeavesTheCacheEntryUnchanged(t *testing.T) {
+	useMemoryCache(t)
+	var version int32 = 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<article><h2><a href="/post">Version %d</a></h2><p>Some text.</p></article>`, atomic.LoadInt32(&version))
+	}))
+	defer server.Close()
+	getRoute(blogRoute(server.URL+"/", "cached"))
+	atomic.StoreInt32(&version, 2)
+	for _, mode := range []string{"bypass", "nocache"} {
+		live := getRoute(blogRoute(server.URL+"/", mode))
+		if live.Header().Get("cached") != "bypass" || live.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("expected %s to be marked as bypassing the cache, got headers %v", mode, live.Header())
+		}
+		if !strings.Contains(live.Body.String(), "Version 2") {
+			t.Errorf("expected %s to read the page live, got %s", mode, live.Body.String())
+		}
+	}
+	cached := getRoute(blogRoute(server.URL+"/", "cached"))
+	if cached.Header().Get("cached") == "-" || !strings.Contains(cached.Body.String(), "Version 1") {
+		t.Errorf("expected the first copy still cached after bypassed reads, got %s", cached.Body.String())
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+// date formats publishers use in datetime and content attributes, most specific first
+var publishedLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func parsePublished(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	for i := 0; i < len(publishedLayouts); i++ {
+		if t, err := time.Parse(publishedLayouts[i], value); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// articlePublished reads the publication date marked up within an article as RFC3339,
+// falling back to the page's article:published_time for a page with a single article
+func articlePublished(article *goquery.Selection, bow *browser.Browser, numArticles int) string {
+	candidates := []string{
+		article.Find("[itemprop='datePublished']").First().AttrOr("content", ""),
+		article.Find("[itemprop='datePublished']").First().AttrOr("datetime", ""),
+		article.Find("time[datetime]").First().AttrOr("datetime", ""),
+	}
+	if numArticles == 1 {
+		candidates = append(candidates, bow.Find("meta[property='article:published_time']").First().AttrOr("content", ""))
+	}
+	for i := 0; i < len(candidates); i++ {
+		if t, ok := parsePublished(candidates[i]); ok {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return ""
+}
+
+// relative dates count back from now, e.g. 24h, 7d or 2w
+var relativeDateRgx = regexp.MustCompile(`^(\d+)([hdw])$`)
+
+// parseDateParam reads a ?since or ?until value, an RFC3339 time, a date or a relative period
+func parseDateParam(value string, now time.Time) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) < 1 {
+		return time.Time{}, false
+	}
+	if parts := relativeDateRgx.FindStringSubmatch(strings.ToLower(value)); parts != nil {
+		num, _ := strconv.Atoi(parts[1])
+		unit := 24 * time.Hour
+		switch parts[2] {
+		case "h":
+			unit = time.Hour
+		case "w":
+			unit = 7 * 24 * time.Hour
+		}
+		return now.Add(-time.Duration(num) * unit), true
+	}
+	return parsePublished(value)
+}
+
+// DateRange limits articles to those published from Since to Until, either of which may be unset
+type DateRange struct {
+	Since          time.Time
+	Until          time.Time
+	ExcludeUndated bool
+}
+
+func (dr DateRange) isSet() bool {
+	return !dr.Since.IsZero() || !dr.Until.IsZero()
+}
+
+// filterArticlesByDate applies the range to the articles of a response, after caching,
+// so relative ranges are evaluated on each request
+func filterArticlesByDate(articles []Article, dr DateRange) []Article {
+	if !dr.isSet() {
+		return articles
+	}
+	kept := []Article{}
+	for i := 0; i < len(articles); i++ {
+		published, ok := parsePublished(articles[i].Published)
+		if !ok {
+			if !dr.ExcludeUndated {
+				kept = append(kept, articles[i])
+			}
+			continue
+		}
+		if !dr.Since.IsZero() && published.Before(dr.Since) {
+			continue
+		}
+		if !dr.Until.IsZero() && published.After(dr.Until) {
+			continue
+		}
+		kept = append(kept, articles[i])
+	}
+	return kept
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDateRangeRejectsMalformedDates(t *testing.T) {
+	for _, query := range []string{"since=yesterday", "until=2024-13-01", "since=7x"} {
+		r := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		if _, err := dateRangeFromRequest(r); err == nil {
+			t.Errorf("expected %s to be rejected", query)
+		}
+		w := httptest.NewRecorder()
+		if validDateRange(w, r) || w.Code != http.StatusBadRequest {
+			t.Errorf("expected a 400 for %s, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestDateRangeUntilTakesInTheWholeDay(t *testing.T) {
+	dr, err := dateRangeFromRequest(httptest.NewRequest(http.MethodGet, "/?since=2024-03-01&until=2024-03-02", nil))
+	if err != nil {
+		t.Fatalf("expected valid dates, got %v", err)
+	}
+	articles := []Article{
+		{Title: "before", Published: "2024-02-29T23:00:00Z"},
+		{Title: "first", Published: "2024-03-01T00:00:00Z"},
+		{Title: "evening", Published: "2024-03-02T21:30:00Z"},
+		{Title: "after", Published: "2024-03-03T00:00:00Z"},
+		{Title: "undated"},
+	}
+	kept := filterArticlesByDate(articles, dr)
+	if len(kept) != 3 || kept[0].Title != "first" || kept[1].Title != "evening" || kept[2].Title != "undated" {
+		t.Errorf("expected first, evening and undated, got %+v", kept)
+	}
+	dr, _ = dateRangeFromRequest(httptest.NewRequest(http.MethodGet, "/?until=2024-03-02T12:00:00Z&undated=exclude", nil))
+	if kept = filterArticlesByDate(articles, dr); len(kept) != 2 {
+		t.Errorf("expected an until time to be exact, got %+v", kept)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type PageDiff struct {
+	Uri string `json:"uri"`
+	// Baseline is false when no cached copy was found, so every live article is reported as added
+	Baseline       bool     `json:"baseline"`
+	ContentChanged bool     `json:"contentChanged"`
+	Added          []string `json:"added"`
+	Removed        []string `json:"removed"`
+	Changed        []string `json:"changed"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// articleKey matches articles across crawls by their link, or their title when they have none
+func articleKey(article Article) string {
+	if len(article.Uri) > 0 {
+		return article.Uri
+	}
+	return article.Title
+}
+
+// diffArticles lists the titles of articles only in current, only in previous, and in both
+// with a different title or content
+func diffArticles(previous []Article, current []Article) (added []string, removed []string, changed []string) {
+	added, removed, changed = []string{}, []string{}, []string{}
+	before := map[string]Article{}
+	for i := 0; i < len(previous); i++ {
+		before[articleKey(previous[i])] = previous[i]
+	}
+	seen := map[string]bool{}
+	for i := 0; i < len(current); i++ {
+		key := articleKey(current[i])
+		seen[key] = true
+		old, exists := before[key]
+		if !exists {
+			added = append(added, current[i].Title)
+		} else if old.Title != current[i].Title || contentHash(old.Content) != contentHash(current[i].Content) {
+			changed = append(changed, current[i].Title)
+		}
+	}
+	for i := 0; i < len(previous); i++ {
+		if !seen[articleKey(previous[i])] {
+			removed = append(removed, previous[i].Title)
+		}
+	}
+	return
+}
+
+// diffPage compares a live read of the page with its cached copy, leaving the cache as it was.
+// When the live read fails only the error is reported, as there is nothing to compare
+func diffPage(ctx context.Context, path string, scheme string, opts FetchOptions) PageDiff {
+	path = normalizePath(path)
+	uri := effectiveScheme(scheme) + "://" + path
+	diff := PageDiff{Uri: uri}
+	previous := emptyPage()
+	result, err := getCache(ctx, pageCacheKey(path)+opts.cacheSuffix())
+	if err == nil {
+		previous = result.(Page)
+		diff.Baseline = true
+	} else if err != errCacheMiss {
+		opts.logger().Printf("cache read failed path=%s error=%q", path, err.Error())
+	}
+	current := readLiveBlogPage(ctx, uri, opts)
+	if len(current.Error) > 0 || !current.Exists {
+		diff.Error = current.Error
+		if len(diff.Error) < 1 {
+			diff.Error = "page not found"
+		}
+		diff.Added, diff.Removed, diff.Changed = []string{}, []string{}, []string{}
+		return diff
+	}
+	diff.ContentChanged = diff.Baseline && previous.ContentHash != current.ContentHash
+	diff.Added, diff.Removed, diff.Changed = diffArticles(previous.Articles, current.Articles)
+	return diff
+}
+
+func diffJson(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	data := diffPage(r.Context(), vars["url"], scheme, fetchOptionsFromRequest(r))
+	writeJson(w, r, data)
+}
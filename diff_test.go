@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFailedLiveReadsReportOnlyTheError(t *testing.T) {
+	useMemoryCache(t)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	path := closed.Listener.Addr().String() + "/post"
+	cached := Page{Uri: "http://" + path, Exists: true, ContentHash: "abc", Articles: []Article{{Title: "Post", Uri: "/post"}}}
+	setCache(context.Background(), pageCacheKey(path)+FetchOptions{}.cacheSuffix(), cached, 10)
+	diff := diffPage(context.Background(), path, "http", FetchOptions{})
+	if !diff.Baseline || len(diff.Error) < 1 {
+		t.Fatalf("expected the cached baseline and an error, got %+v", diff)
+	}
+	if diff.ContentChanged || len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 0 {
+		t.Errorf("expected no changes reported for a failed read, got %+v", diff)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/headzoo/surf/browser"
+)
+
+// primary language subtags written right to left
+var rtlLanguages = []string{"ar", "arc", "ckb", "dv", "fa", "he", "iw", "ku", "ps", "sd", "syr", "ug", "ur", "yi"}
+
+func isRtlLanguage(lang string) bool {
+	primary := strings.ToLower(strings.TrimSpace(strings.SplitN(strings.Replace(lang, "_", "-", -1), "-", 2)[0]))
+	return stringInList(rtlLanguages, primary)
+}
+
+// readDirection returns ltr or rtl from <html dir>, falling back to the declared
+// language of the document and then to the requested language
+func readDirection(bow *browser.Browser, requestLang string) string {
+	html := bow.Find("html")
+	dir := strings.ToLower(strings.TrimSpace(html.AttrOr("dir", "")))
+	if dir == "rtl" || dir == "ltr" {
+		return dir
+	}
+	lang := strings.TrimSpace(html.AttrOr("lang", ""))
+	if len(lang) < 1 {
+		// Accept-Language style values list the preferred language first
+		lang = strings.SplitN(requestLang, ",", 2)[0]
+	}
+	if isRtlLanguage(lang) {
+		return "rtl"
+	}
+	return "ltr"
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const linksAndMedia = `<html><body><nav><a href="/one">one two three</a><a href="/four">four five</a></nav>` +
+	`<p>Six seven <a href="/eight">eight</a> nine.</p><figure><img src="/a.png"><figcaption>ten eleven</figcaption></figure></body></html>`
+
+func TestWordsNotInLinksLeaveOutLinksAndMedia(t *testing.T) {
+	server := serveHtml(t, linksAndMedia)
+	ps := discoverLivePage(context.Background(), server.URL+"/", FetchOptions{})
+	if words := countValue(ps, "wordsNotInLinks"); words != 3 {
+		t.Errorf("expected 3 words outside links and media, got %d", words)
+	}
+	if words := countWordsNotInLinks(openHtml(t, linksAndMedia)); words != 3 {
+		t.Errorf("expected the thin content count to match discover, got %d", words)
+	}
+}
+
+func TestClassPathOfDeeplyNestedBlocksIsBounded(t *testing.T) {
+	html := "<html><body>" + strings.Repeat(`<div class="wrap">`, 300) + `<p id="deep">Deep text.</p>` + strings.Repeat("</div>", 300) + "</body></html>"
+	bow := openHtml(t, html)
+	set := buildClassesIdSetWithin(bow.Find("#deep"), 4)
+	if path := set.ToPath(); strings.Count(path, " ") > 4 || !strings.HasSuffix(path, "p#deep") {
+		t.Errorf("expected at most 4 ancestors in the path, got %q", path)
+	}
+	set = buildClassesIdSet(bow.Find("#deep"))
+	if path := set.ToPath(); strings.Count(path, " ") > classPathMaxDepth {
+		t.Errorf("expected at most %d ancestors by default, got %d", classPathMaxDepth, strings.Count(path, " "))
+	}
+	shallow := openHtml(t, `<html><body><main id="content"><section class="post"><p id="text">Text.</p></section></main></body></html>`)
+	set = buildClassesIdSet(shallow.Find("#text"))
+	if path := set.ToPath(); path != "main#content section.post p#text" {
+		t.Errorf("expected the full path of a shallow block, got %q", path)
+	}
+}
+
+const sidebarLayout = `<html><body><main><p>One two three four five.</p></main> ` +
+	`<aside><p>Six seven eight.</p></aside> <div class="related"><p>Nine ten.</p></div></body></html>`
+
+func TestContentWordsLeaveOutTheSidebar(t *testing.T) {
+	server := serveHtml(t, sidebarLayout)
+	ps := discoverLivePage(context.Background(), server.URL+"/", FetchOptions{})
+	if words := countValue(ps, "words"); words != 10 {
+		t.Errorf("expected 10 words on the page, got %d", words)
+	}
+	if words := countValue(ps, "contentWords"); words != 5 {
+		t.Errorf("expected 5 words outside the sidebar and related posts, got %d", words)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// resolved addresses are reused for DNS_CACHE_TTL_SECONDS, 0 disables the cache
+var dnsCacheTtl = time.Duration(envInt("DNS_CACHE_TTL_SECONDS", 60)) * time.Second
+
+// most hosts held by the DNS cache
+var dnsCacheSize = envInt("DNS_CACHE_SIZE", 1000)
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache is a bounded cache of host lookups shared by every fetch
+type dnsCache struct {
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+	ttl     time.Duration
+	size    int
+	hits    int64
+	misses  int64
+}
+
+var sharedDnsCache = &dnsCache{entries: map[string]dnsEntry{}, ttl: dnsCacheTtl, size: dnsCacheSize}
+
+// evict drops expired entries and, when the cache is still full, the entry closest to expiry
+func (dc *dnsCache) evict(now time.Time) {
+	var oldest string
+	for host, entry := range dc.entries {
+		if now.After(entry.expires) {
+			delete(dc.entries, host)
+		} else if len(oldest) < 1 || entry.expires.Before(dc.entries[oldest].expires) {
+			oldest = host
+		}
+	}
+	if len(dc.entries) >= dc.size && len(oldest) > 0 {
+		delete(dc.entries, oldest)
+	}
+}
+
+func (dc *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := time.Now()
+	dc.mu.Lock()
+	entry, exists := dc.entries[host]
+	dc.mu.Unlock()
+	if exists && now.Before(entry.expires) {
+		atomic.AddInt64(&dc.hits, 1)
+		return entry.addrs, nil
+	}
+	atomic.AddInt64(&dc.misses, 1)
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	dc.mu.Lock()
+	if len(dc.entries) >= dc.size {
+		dc.evict(now)
+	}
+	dc.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(dc.ttl)}
+	dc.mu.Unlock()
+	return addrs, nil
+}
+
+func (dc *dnsCache) length() int {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return len(dc.entries)
+}
+
+// dialContext resolves the host through the cache and tries each address in turn,
+// TLS server names still come from the request so certificates are checked against the host
+func (dc *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		addrs, err := dc.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var lastErr error
+		for i := 0; i < len(addrs); i++ {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(addrs[i], port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, lastErr
+	}
+}
+
+// metricsText reports the DNS cache counters in the Prometheus text format
+func metricsText() string {
+	return fmt.Sprintf("# TYPE crawler_dns_cache_hits_total counter\ncrawler_dns_cache_hits_total %d\n"+
+		"# TYPE crawler_dns_cache_misses_total counter\ncrawler_dns_cache_misses_total %d\n"+
+		"# TYPE crawler_dns_cache_entries gauge\ncrawler_dns_cache_entries %d\n",
+		atomic.LoadInt64(&sharedDnsCache.hits), atomic.LoadInt64(&sharedDnsCache.misses), sharedDnsCache.length())
+}
+
+func metricsPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(w, metricsText())
+}
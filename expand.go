@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type ExpandedLink struct {
+	Uri        string   `json:"uri"`
+	Exists     bool     `json:"exists"`
+	StatusCode int      `json:"statusCode"`
+	FinalUri   string   `json:"finalUri"`
+	Redirects  []string `json:"redirects"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// expandLink follows the redirect chain of a shortened link without downloading the destination body
+func expandLink(ctx context.Context, uri string, opts FetchOptions) ExpandedLink {
+	expanded := ExpandedLink{Uri: uri, Redirects: []string{}}
+	client := &http.Client{
+		Transport: sharedTransport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := guardRedirect(req, via); err != nil {
+				return err
+			}
+			expanded.Redirects = append(expanded.Redirects, req.URL.String())
+			return nil
+		},
+	}
+	resp, err := sendProbeRequest(ctx, client, http.MethodHead, uri, opts)
+	if err != nil || headIsRejected(resp.StatusCode) {
+		expanded.Redirects = []string{}
+		resp, err = sendProbeRequest(ctx, client, http.MethodGet, uri, opts)
+	}
+	if err != nil {
+		opts.logger().Printf("expand failed uri=%s error=%q", uri, err.Error())
+		expanded.Error = fetchErrorMessage(err)
+		return expanded
+	}
+	expanded.StatusCode = resp.StatusCode
+	expanded.Exists = resp.StatusCode < 400
+	expanded.FinalUri = resp.Request.URL.String()
+	return expanded
+}
+
+func expandPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	data := expandLink(r.Context(), url, fetchOptionsFromRequest(r))
+	writeJson(w, r, data)
+}
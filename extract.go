@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+// ExtractOptions holds per-request settings that change how content is extracted
+type ExtractOptions struct {
+	// SegmentHeadings splits pages without articles into sections at h2/h3 boundaries
+	SegmentHeadings bool
+	// TitleSelectors are tried in order within each article before falling back to h1-h3
+	TitleSelectors []string
+	// BlockTags are the candidate content containers compared by discover
+	BlockTags []string
+	// Keyword is a word or phrase whose density is reported by discover
+	Keyword string
+	// MinWords marks pages with fewer words outside links as soft errors, 0 disables the check
+	MinWords int
+	// MinTitleWords and MinTitleChars drop articles with shorter titles, such as Archives or
+	// Categories widgets, defaulting to MIN_TITLE_WORDS and MIN_TITLE_CHARS
+	MinTitleWords int
+	MinTitleChars int
+	// MergePages appends the content of rel=next pages to a single article
+	MergePages bool
+	// Partial returns the articles extracted before the fetch deadline instead of finishing late
+	Partial bool
+	// MaxContentBytes caps the html content of each article, 0 leaves it unlimited
+	MaxContentBytes int
+	// MaxContentChars caps each article's content in characters and appends an ellipsis, 0 leaves it unlimited
+	MaxContentChars int
+	// Dedupe drops articles repeating the permalink or the text of an earlier article on the page,
+	// on unless ?dedupe=false asks for the raw list
+	Dedupe bool
+	// Sanitize strips scripts, event handlers and unsafe urls from article content
+	Sanitize bool
+	// IncludeNoscript treats <noscript> fallbacks as page content, by default they are
+	// removed as they usually repeat lazy loaded content
+	IncludeNoscript bool
+	// Readable replaces the article loop with a readability style pick of the main content block
+	Readable bool
+	// RetryEmpty fetches a page again once when it exists but yields no articles
+	RetryEmpty bool
+	// Render fetches near empty pages again through the render service, see RENDER_URL
+	Render bool
+	// RenderAlways renders every page through the render service, for sites known to be built by scripts
+	RenderAlways bool
+	// Comments lists the comments of the page's comment threads, which are counted in any case
+	Comments bool
+	// GroupLinks also lists the links of the page by their nearest nav, header, footer, article, aside or main
+	GroupLinks bool
+	// MaxLinks caps the links of the page in responses, not in the cache, 0 leaves them uncapped
+	MaxLinks int
+	// Published filters the articles of responses by publication date, not those cached
+	Published DateRange
+	// RequireLang empties responses for pages known to be in another language, not those cached
+	RequireLang string
+}
+
+func queryBool(r *http.Request, key string) bool {
+	val, err := strconv.ParseBool(strings.TrimSpace(r.URL.Query().Get(key)))
+	return err == nil && val
+}
+
+// queryBoolOr reads a boolean query param, falling back when it is absent or invalid
+func queryBoolOr(r *http.Request, key string, fallback bool) bool {
+	val, err := strconv.ParseBool(strings.TrimSpace(r.URL.Query().Get(key)))
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+// queryInt reads a non-negative integer query param, returning 0 when absent or invalid
+func queryInt(r *http.Request, key string) int {
+	val, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get(key)))
+	if err != nil || val < 0 {
+		return 0
+	}
+	return val
+}
+
+// queryIntOr reads a non-negative integer param, falling back when it is absent or invalid
+func queryIntOr(r *http.Request, key string, fallback int) int {
+	val, err := strconv.Atoi(strings.TrimSpace(r.URL.Query().Get(key)))
+	if err != nil || val < 0 {
+		return fallback
+	}
+	return val
+}
+
+// queryList reads a comma separated query param, also accepting the param repeated
+func queryList(r *http.Request, key string) []string {
+	items := []string{}
+	values := r.URL.Query()[key]
+	for i := 0; i < len(values); i++ {
+		parts := strings.Split(values[i], ",")
+		for j := 0; j < len(parts); j++ {
+			item := strings.TrimSpace(parts[j])
+			if len(item) > 0 {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+var defaultBlockTags = []string{"div", "article", "section", "aside"}
+
+func (eo ExtractOptions) blockTagSelector() string {
+	if len(eo.BlockTags) < 1 {
+		return strings.Join(defaultBlockTags, ", ")
+	}
+	return strings.Join(eo.BlockTags, ", ")
+}
+
+// prepareNoscript removes <noscript> elements from the document, or with IncludeNoscript
+// replaces each by its content, which the parser keeps as unparsed text
+func prepareNoscript(bow *browser.Browser, opts ExtractOptions) {
+	noscripts := bow.Find("noscript")
+	if !opts.IncludeNoscript {
+		noscripts.Remove()
+		return
+	}
+	noscripts.Each(func(_ int, s *goquery.Selection) {
+		s.ReplaceWithHtml(s.Text())
+	})
+}
+
+// dateRangeFromRequest reads ?since and ?until, ?undated=exclude drops articles without a date.
+// A date without a time as until takes in the whole of that day. Malformed values are returned as an error
+func dateRangeFromRequest(r *http.Request) (DateRange, error) {
+	query := r.URL.Query()
+	now := time.Now()
+	dr := DateRange{ExcludeUndated: strings.EqualFold(query.Get("undated"), "exclude")}
+	since, until := strings.TrimSpace(query.Get("since")), strings.TrimSpace(query.Get("until"))
+	var valid bool
+	if dr.Since, valid = parseDateParam(since, now); !valid && len(since) > 0 {
+		return dr, fmt.Errorf("invalid since date: %s", since)
+	}
+	if dr.Until, valid = parseDateParam(until, now); !valid && len(until) > 0 {
+		return dr, fmt.Errorf("invalid until date: %s", until)
+	}
+	if _, err := time.Parse("2006-01-02", until); err == nil {
+		dr.Until = dr.Until.Add(24*time.Hour - time.Nanosecond)
+	}
+	return dr, nil
+}
+
+// validDateRange writes a 400 error and returns false when ?since or ?until cannot be read
+func validDateRange(w http.ResponseWriter, r *http.Request) bool {
+	if _, err := dateRangeFromRequest(r); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return false
+	}
+	return true
+}
+
+// isLongEnoughTitle checks an article title against the minimum words and characters
+func (eo ExtractOptions) isLongEnoughTitle(title string) bool {
+	title = strings.TrimSpace(title)
+	return len(strings.Fields(title)) >= eo.MinTitleWords && utf8.RuneCountInString(title) >= eo.MinTitleChars
+}
+
+func extractOptionsFromRequest(r *http.Request) ExtractOptions {
+	renderJs := strings.EqualFold(r.URL.Query().Get("render"), "js")
+	// malformed dates are rejected by the handlers filtering on them
+	published, _ := dateRangeFromRequest(r)
+	return ExtractOptions{
+		SegmentHeadings: queryBool(r, "segment"),
+		TitleSelectors:  queryList(r, "titleSelectors"),
+		BlockTags:       queryList(r, "tags"),
+		Keyword:         strings.TrimSpace(r.URL.Query().Get("keyword")),
+		MinWords:        queryInt(r, "minWords"),
+		MinTitleWords:   queryIntOr(r, "minTitleWords", minTitleWords),
+		MinTitleChars:   queryIntOr(r, "minTitleChars", minTitleChars),
+		MergePages:      queryBool(r, "paginate"),
+		Partial:         queryBool(r, "partial"),
+		MaxContentBytes: queryInt(r, "maxContentBytes"),
+		MaxContentChars: queryInt(r, "maxContentChars"),
+		Dedupe:          queryBoolOr(r, "dedupe", true),
+		Sanitize:        queryBool(r, "sanitize"),
+		IncludeNoscript: strings.EqualFold(r.URL.Query().Get("noscript"), "include"),
+		Readable:        strings.EqualFold(r.URL.Query().Get("mode"), "readable"),
+		RetryEmpty:      queryBool(r, "retryEmpty"),
+		Render:          queryBool(r, "render") || renderJs,
+		RenderAlways:    renderJs,
+		Comments:        queryBool(r, "comments"),
+		GroupLinks:      queryBool(r, "groupLinks"),
+		MaxLinks:        queryInt(r, "maxLinks"),
+		Published:       published,
+		RequireLang:     strings.TrimSpace(r.URL.Query().Get("requireLang")),
+	}
+}
+
+// cacheSuffix distinguishes cache entries extracted with non-default options
+func (eo ExtractOptions) cacheSuffix() string {
+	parts := []string{}
+	if eo.SegmentHeadings {
+		parts = append(parts, "segment")
+	}
+	if len(eo.TitleSelectors) > 0 {
+		parts = append(parts, "titles="+strings.Join(eo.TitleSelectors, ","))
+	}
+	if len(eo.BlockTags) > 0 {
+		parts = append(parts, "tags="+strings.Join(eo.BlockTags, ","))
+	}
+	if len(eo.Keyword) > 0 {
+		parts = append(parts, "keyword="+eo.Keyword)
+	}
+	if eo.MergePages {
+		parts = append(parts, "paginate")
+	}
+	if eo.Readable {
+		parts = append(parts, "readable")
+	}
+	if eo.IncludeNoscript {
+		parts = append(parts, "noscript")
+	}
+	if eo.Sanitize {
+		parts = append(parts, "sanitize")
+	}
+	if !eo.Dedupe {
+		parts = append(parts, "raw")
+	}
+	if eo.MaxContentBytes > 0 {
+		parts = append(parts, "maxContentBytes="+strconv.Itoa(eo.MaxContentBytes))
+	}
+	if eo.Comments {
+		parts = append(parts, "comments")
+	}
+	if eo.GroupLinks {
+		parts = append(parts, "groupLinks")
+	}
+	if renderEnabled(eo) && eo.RenderAlways {
+		parts = append(parts, "render=js")
+	} else if renderEnabled(eo) {
+		parts = append(parts, "render")
+	}
+	if eo.MaxContentChars > 0 {
+		parts = append(parts, "maxContentChars="+strconv.Itoa(eo.MaxContentChars))
+	}
+	if eo.MinTitleWords != minTitleWords || eo.MinTitleChars != minTitleChars {
+		parts = append(parts, "minTitle="+strconv.Itoa(eo.MinTitleWords)+","+strconv.Itoa(eo.MinTitleChars))
+	}
+	if eo.MinWords > 0 {
+		parts = append(parts, "minWords="+strconv.Itoa(eo.MinWords))
+	}
+	if len(parts) < 1 {
+		return ""
+	}
+	return ":" + strings.Join(parts, ":")
+}
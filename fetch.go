@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/headzoo/surf/browser"
+	"gopkg.in/headzoo/surf.v1"
+)
+
+// FetchOptions holds per-request settings applied to the upstream fetch
+type FetchOptions struct {
+	Logger  *log.Logger
+	Headers http.Header
+	// Cookies may hold session credentials, so their values must never be logged
+	Cookies []*http.Cookie
+	Extract ExtractOptions
+	// Lang is the preferred content language, e.g. en or fr-CA
+	Lang string
+	// Validators are the If-None-Match and If-Modified-Since headers of a cached copy
+	Validators http.Header
+	// Polite spaces fetches to each host with the host rate limiter, as crawls and batches do
+	Polite bool
+}
+
+// only these headers may be forwarded upstream, overridable with FORWARD_HEADERS
+var forwardHeaders = envList("FORWARD_HEADERS", []string{"Accept", "Accept-Language", "Referer", "DNT", "Save-Data"})
+
+func isForwardableHeader(name string) bool {
+	canonical := http.CanonicalHeaderKey(name)
+	for i := 0; i < len(forwardHeaders); i++ {
+		if http.CanonicalHeaderKey(forwardHeaders[i]) == canonical {
+			return true
+		}
+	}
+	return false
+}
+
+// addForwardHeader sets a header to forward upstream, dropping any header not in the whitelist
+func addForwardHeader(headers http.Header, name string, value string) {
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	if len(name) > 0 && len(value) > 0 && isForwardableHeader(name) {
+		headers.Set(name, value)
+	}
+}
+
+// parseHeaderParams reads repeatable ?header=Name:value params
+func parseHeaderParams(params []string) http.Header {
+	headers := http.Header{}
+	for i := 0; i < len(params); i++ {
+		parts := strings.SplitN(params[i], ":", 2)
+		if len(parts) == 2 {
+			addForwardHeader(headers, parts[0], parts[1])
+		}
+	}
+	return headers
+}
+
+// headersDigest fingerprints forwarded headers for cache keys, without exposing their values
+func headersDigest(headers http.Header) string {
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for i := 0; i < len(names); i++ {
+		fmt.Fprintf(hash, "%s:%s\n", strings.ToLower(names[i]), strings.Join(headers[names[i]], ","))
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// parseCookieParams reads repeatable ?cookie=name=value params
+func parseCookieParams(params []string) []*http.Cookie {
+	cookies := []*http.Cookie{}
+	for i := 0; i < len(params); i++ {
+		parts := strings.SplitN(params[i], "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		if len(name) > 0 {
+			cookies = append(cookies, &http.Cookie{Name: name, Value: strings.TrimSpace(parts[1])})
+		}
+	}
+	return cookies
+}
+
+// language values are limited to the characters of an Accept-Language header, e.g. fr-CA,fr;q=0.8
+var validLangRgx = regexp.MustCompile(`^[A-Za-z0-9*\-_,;=. ]{1,64}$`)
+
+func parseLang(lang string) string {
+	lang = strings.TrimSpace(lang)
+	if !validLangRgx.MatchString(lang) {
+		return ""
+	}
+	return lang
+}
+
+func fetchOptionsFromRequest(r *http.Request) FetchOptions {
+	query := r.URL.Query()
+	return FetchOptions{
+		Logger:  requestLogger(r),
+		Headers: parseHeaderParams(query["header"]),
+		Cookies: parseCookieParams(query["cookie"]),
+		Extract: extractOptionsFromRequest(r),
+		Lang:    parseLang(query.Get("lang")),
+	}
+}
+
+func (opts FetchOptions) logger() *log.Logger {
+	if opts.Logger == nil {
+		return defaultLogger
+	}
+	return opts.Logger
+}
+
+// contextTransport binds requests to a context, as surf builds its requests without one,
+// so a fetch stops once the incoming request is cancelled
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (ct *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return ct.base.RoundTrip(req.WithContext(ct.ctx))
+}
+
+// withTimeout bounds ctx by timeout unless it is 0 or negative
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// language returns the requested content language, or DEFAULT_LANG when none was given
+func (opts FetchOptions) language() string {
+	if len(opts.Lang) > 0 {
+		return opts.Lang
+	}
+	return defaultLang
+}
+
+// cacheSuffix extends the extraction suffix with the requested language and forwarded headers,
+// so localised versions of a page, or versions served for other headers, are cached separately
+func (opts FetchOptions) cacheSuffix() string {
+	suffix := opts.Extract.cacheSuffix()
+	if len(opts.Lang) > 0 {
+		suffix += ":lang=" + strings.ToLower(strings.Replace(opts.Lang, " ", "", -1))
+	}
+	if len(opts.Headers) > 0 {
+		suffix += ":headers=" + headersDigest(opts.Headers)
+	}
+	return suffix
+}
+
+// isPrivate reports a fetch with cookies, which may unlock content meant for one user,
+// so it is neither read from nor written to the cache
+func (opts FetchOptions) isPrivate() bool {
+	return len(opts.Cookies) > 0
+}
+
+// requestHeaders merges the forwarded headers with an Accept-Language header for the language,
+// an explicitly forwarded Accept-Language header takes precedence
+func (opts FetchOptions) requestHeaders() http.Header {
+	headers := http.Header{}
+	if lang := opts.language(); len(lang) > 0 {
+		headers.Set("Accept-Language", lang)
+	}
+	for name, values := range opts.Headers {
+		headers[name] = values
+	}
+	for name, values := range opts.Validators {
+		headers[name] = values
+	}
+	return headers
+}
+
+// conditionalHeaders builds the validators for a refresh from the stored upstream headers,
+// so an unchanged page can be answered with 304 Not Modified
+func conditionalHeaders(upstream map[string]string) http.Header {
+	headers := http.Header{}
+	if etag := upstream["Etag"]; len(etag) > 0 {
+		headers.Set("If-None-Match", etag)
+	}
+	if lastModified := upstream["Last-Modified"]; len(lastModified) > 0 {
+		headers.Set("If-Modified-Since", lastModified)
+	}
+	return headers
+}
+
+func applyHeaders(headers http.Header, set func(name string, value string)) {
+	for name, values := range headers {
+		if len(values) > 0 {
+			set(name, values[0])
+		}
+	}
+}
+
+// sharedTransport is reused by every upstream fetch so connections to a host are kept alive between requests
+var sharedTransport = newSharedTransport()
+
+// probeClient is used for requests that skip the browser, such as head checks and sitemaps,
+// and follows redirects under the same MAX_REDIRECTS and loop checks as the browser
+var probeClient = &http.Client{Transport: sharedTransport, CheckRedirect: guardRedirect}
+
+func newSharedTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = true
+	transport.DisableKeepAlives = false
+	transport.MaxIdleConns = envInt("MAX_IDLE_CONNS", 200)
+	transport.MaxIdleConnsPerHost = envInt("MAX_IDLE_CONNS_PER_HOST", 16)
+	transport.IdleConnTimeout = time.Duration(envInt("IDLE_CONN_TIMEOUT_SECONDS", 90)) * time.Second
+	if dnsCacheTtl > 0 && dnsCacheSize > 0 {
+		transport.DialContext = sharedDnsCache.dialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second})
+	}
+	// INSECURE_SKIP_VERIFY accepts any certificate, including self-signed ones on internal staging hosts.
+	// Responses can then be forged by anyone able to intercept traffic, so it must only be enabled
+	// for trusted networks and never on a deployment that crawls the public web.
+	if envBool("INSECURE_SKIP_VERIFY", false) {
+		log.Printf("warning: INSECURE_SKIP_VERIFY is enabled, TLS certificates of crawled sites are not verified")
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+	return transport
+}
+
+// newBrowser builds a surf browser configured for a single upstream fetch of uri
+func newBrowser(ctx context.Context, uri string, opts FetchOptions) *browser.Browser {
+	bow := surf.NewBrowser()
+	bow.SetTransport(&contextTransport{ctx: ctx, base: &redirectGuard{base: &contentTypeGuard{base: sharedTransport}}})
+	applyHeaders(opts.requestHeaders(), bow.AddRequestHeader)
+	if len(opts.Cookies) > 0 {
+		if target, err := url.Parse(uri); err == nil {
+			bow.CookieJar().SetCookies(target, opts.Cookies)
+		}
+	}
+	return bow
+}
+
+// fetchPage opens uri in a new browser, logging the outcome against the request
+// and aborting it when ctx is cancelled
+func fetchPage(ctx context.Context, uri string, opts FetchOptions) (*browser.Browser, error) {
+	bow := newBrowser(ctx, uri, opts)
+	if opts.Polite {
+		if target, err := url.Parse(uri); err == nil && !hostRateLimiter.wait(ctx, target.Host, hostMaxWait) {
+			if ctx.Err() != nil {
+				return bow, ctx.Err()
+			}
+			opts.logger().Printf("fetch skipped uri=%s host busy", uri)
+			return bow, errHostBusy
+		}
+	}
+	start := time.Now()
+	err := bow.Open(uri)
+	if err != nil {
+		opts.logger().Printf("fetch failed uri=%s error=%q", uri, err.Error())
+	} else {
+		opts.logger().Printf("fetch uri=%s status=%d duration=%s", uri, bow.StatusCode(), time.Since(start))
+	}
+	return bow, err
+}
+
+var hopByHopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// upstreamHeaders flattens the response headers of the fetched page, leaving out hop-by-hop
+// headers, including any named in Connection, and Set-Cookie, which may carry session tokens
+func upstreamHeaders(header http.Header) map[string]string {
+	skip := map[string]bool{"Set-Cookie": true}
+	for i := 0; i < len(hopByHopHeaders); i++ {
+		skip[hopByHopHeaders[i]] = true
+	}
+	for _, name := range strings.Split(header.Get("Connection"), ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			skip[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+	headers := map[string]string{}
+	for name, values := range header {
+		if !skip[http.CanonicalHeaderKey(name)] {
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingServer serves a short article page, counts requests and keeps the headers of the last one
+type recordingServer struct {
+	*httptest.Server
+	mu     sync.Mutex
+	header http.Header
+	hits   int
+}
+
+func newRecordingServer(t *testing.T) *recordingServer {
+	rs := &recordingServer{}
+	rs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs.mu.Lock()
+		rs.header = r.Header.Clone()
+		rs.hits++
+		rs.mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><body><article><h2><a href="/post">Post</a></h2><p>Some text.</p></article></body></html>`)
+	}))
+	t.Cleanup(rs.Server.Close)
+	return rs
+}
+
+func (rs *recordingServer) lastHeader(name string) string {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.header.Get(name)
+}
+
+func TestCookiesAreAttachedToTheFetch(t *testing.T) {
+	server := newRecordingServer(t)
+	opts := FetchOptions{Cookies: parseCookieParams([]string{"session=abc123", "theme=dark"})}
+	page := readLiveBlogPage(context.Background(), server.URL+"/post", opts)
+	if !page.Exists {
+		t.Fatalf("fetch failed")
+	}
+	if cookie := server.lastHeader("Cookie"); cookie != "session=abc123; theme=dark" {
+		t.Errorf("expected both cookies on the request, got %q", cookie)
+	}
+}
+
+func TestParseCookieParamsSkipsMalformedPairs(t *testing.T) {
+	cookies := parseCookieParams([]string{"session=a=b", "novalue", "=empty"})
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "a=b" {
+		t.Errorf("expected only session=a=b, got %v", cookies)
+	}
+}
+
+func TestPagesFetchedWithCookiesAreNotCached(t *testing.T) {
+	memory := useMemoryCache(t)
+	server := newRecordingServer(t)
+	path := server.Listener.Addr().String() + "/post"
+	opts := FetchOptions{Cookies: parseCookieParams([]string{"session=abc123"})}
+	readBlogPage(context.Background(), path, "http", true, opts)
+	if keys := len(memory.entries); keys != 0 {
+		t.Errorf("expected nothing cached for a fetch with cookies, got %d entries", keys)
+	}
+	// an anonymous read must not be served the private copy, nor may it be served to a private read
+	readBlogPage(context.Background(), path, "http", true, FetchOptions{})
+	_, isCached, _ := readBlogPage(context.Background(), path, "http", true, opts)
+	if isCached || server.hits != 3 {
+		t.Errorf("expected every private read to be live, got cached=%v after %d fetches", isCached, server.hits)
+	}
+}
+
+func TestParseHeaderParamsKeepsWhitelistedHeaders(t *testing.T) {
+	headers := parseHeaderParams([]string{"accept-language: fr-CA", "X-Secret: 1", "Referer:https://example.com/", "Malformed"})
+	if headers.Get("Accept-Language") != "fr-CA" || headers.Get("Referer") != "https://example.com/" {
+		t.Errorf("expected whitelisted headers to be kept, got %v", headers)
+	}
+	if len(headers) != 2 {
+		t.Errorf("expected X-Secret and the malformed param to be dropped, got %v", headers)
+	}
+}
+
+func TestForwardedHeadersAreAppliedToTheFetch(t *testing.T) {
+	server := newRecordingServer(t)
+	opts := FetchOptions{Headers: parseHeaderParams([]string{"Referer:https://example.com/", "X-Secret:1"})}
+	readLiveBlogPage(context.Background(), server.URL+"/post", opts)
+	if referer := server.lastHeader("Referer"); referer != "https://example.com/" {
+		t.Errorf("expected the forwarded Referer, got %q", referer)
+	}
+	if secret := server.lastHeader("X-Secret"); len(secret) > 0 {
+		t.Errorf("expected X-Secret not to be forwarded, got %q", secret)
+	}
+}
+
+func TestForwardedHeadersSplitTheCacheKey(t *testing.T) {
+	plain := FetchOptions{}.cacheSuffix()
+	english := FetchOptions{Headers: parseHeaderParams([]string{"Accept-Language:en"})}.cacheSuffix()
+	french := FetchOptions{Headers: parseHeaderParams([]string{"Accept-Language:fr"})}.cacheSuffix()
+	if plain == english || english == french {
+		t.Errorf("expected distinct suffixes, got %q, %q and %q", plain, english, french)
+	}
+	if strings.Contains(french, "fr") {
+		t.Errorf("expected header values to be hashed, got %q", french)
+	}
+	both := parseHeaderParams([]string{"Referer:https://example.com/", "Accept-Language:fr"})
+	reversed := parseHeaderParams([]string{"Accept-Language:fr", "Referer:https://example.com/"})
+	if (FetchOptions{Headers: both}).cacheSuffix() != (FetchOptions{Headers: reversed}).cacheSuffix() {
+		t.Errorf("expected the suffix not to depend on header order")
+	}
+}
+
+func TestBatchBodyHeadersAreForwarded(t *testing.T) {
+	useMemoryCache(t)
+	withoutRateLimit(t)
+	server := newRecordingServer(t)
+	body := fmt.Sprintf(`{"urls": ["%s/post"], "headers": {"Accept-Language": "de", "X-Secret": "1"}}`, server.URL)
+	req := httptest.NewRequest(http.MethodPost, "/batch?header=Referer:https://example.com/", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	batchJson(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if lang := server.lastHeader("Accept-Language"); lang != "de" {
+		t.Errorf("expected the batch Accept-Language, got %q", lang)
+	}
+	if referer := server.lastHeader("Referer"); referer != "https://example.com/" {
+		t.Errorf("expected the query Referer alongside the batch headers, got %q", referer)
+	}
+	if secret := server.lastHeader("X-Secret"); len(secret) > 0 {
+		t.Errorf("expected X-Secret not to be forwarded, got %q", secret)
+	}
+}
+
+func TestUpstreamHeadersLeaveOutHopByHopAndCookies(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/html")
+	header.Add("Cache-Control", "public")
+	header.Add("Cache-Control", "max-age=60")
+	header.Set("Set-Cookie", "session=secret")
+	header.Set("Connection", "keep-alive, X-Internal")
+	header.Set("Keep-Alive", "timeout=5")
+	header.Set("X-Internal", "1")
+	headers := upstreamHeaders(header)
+	if headers["Content-Type"] != "text/html" || headers["Cache-Control"] != "public, max-age=60" {
+		t.Errorf("expected the end to end headers, got %v", headers)
+	}
+	if len(headers) != 2 {
+		t.Errorf("expected Set-Cookie, Connection and the headers it names to be left out, got %v", headers)
+	}
+}
+
+func TestUpstreamHeadersAreReturnedOnRequest(t *testing.T) {
+	useMemoryCache(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("X-Served-By", "origin-1")
+		fmt.Fprint(w, `<p>Some text.</p>`)
+	}))
+	defer server.Close()
+	with := getRoute(blogRoute(server.URL+"/post", "cached") + "?headers=1")
+	if !strings.Contains(with.Body.String(), `"X-Served-By":"origin-1"`) {
+		t.Errorf("expected the upstream headers with headers=1, got %s", with.Body.String())
+	}
+	without := getRoute(blogRoute(server.URL+"/post", "cached"))
+	if without.Header().Get("cached") == "-" || strings.Contains(without.Body.String(), `"headers"`) {
+		t.Errorf("expected the cached page without its headers unless asked, got %s", without.Body.String())
+	}
+}
+
+func TestRefreshOfAnUnchangedPageKeepsTheCachedCopy(t *testing.T) {
+	useMemoryCache(t)
+	var mu sync.Mutex
+	conditional := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditional = append(conditional, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `<article><h2><a href="/post">Post</a></h2><p>Some text.</p></article>`)
+	}))
+	defer server.Close()
+	path := server.Listener.Addr().String() + "/post"
+	first, _, _ := readBlogPage(context.Background(), path, "http", true, FetchOptions{})
+	page, isCached, _ := readBlogPage(context.Background(), path, "http", false, FetchOptions{})
+	if len(conditional) != 2 || conditional[0] != "" || conditional[1] != `"v1"` {
+		t.Fatalf("expected the refresh to send the stored ETag, got %q", conditional)
+	}
+	if !isCached || len(page.Articles) != 1 || page.FetchedAt != first.FetchedAt {
+		t.Errorf("expected the cached copy after a 304, got cached=%v with %d articles", isCached, len(page.Articles))
+	}
+}
+
+func TestLangIsSentAndSplitsTheCacheKey(t *testing.T) {
+	memory := useMemoryCache(t)
+	server := newRecordingServer(t)
+	getRoute(blogRoute(server.URL+"/post", "cached") + "?lang=" + url.QueryEscape("fr-CA,fr;q=0.8"))
+	if lang := server.lastHeader("Accept-Language"); lang != "fr-CA,fr;q=0.8" {
+		t.Errorf("expected the requested language upstream, got %q", lang)
+	}
+	getRoute(blogRoute(server.URL+"/post", "cached") + "?lang=de")
+	if lang := server.lastHeader("Accept-Language"); lang != "de" {
+		t.Errorf("expected the second language to be fetched live, got %q", lang)
+	}
+	pages := 0
+	for key := range memory.entries {
+		if strings.HasPrefix(key, pageCacheKey(server.Listener.Addr().String())) {
+			pages++
+		}
+	}
+	if server.hits != 2 || pages != 2 {
+		t.Errorf("expected one fetch and cache entry per language, got %d fetches and %d entries", server.hits, pages)
+	}
+	if parseLang("fr<script>") != "" || (FetchOptions{Lang: "fr"}).cacheSuffix() == (FetchOptions{}).cacheSuffix() {
+		t.Errorf("expected invalid languages dropped and the language in the cache suffix")
+	}
+}
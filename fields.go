@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// jsonFieldNames lists the top level keys a struct type encodes to, including those of
+// anonymous embedded structs, which encoding/json inlines
+func jsonFieldNames(t reflect.Type, names map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && len(name) < 1 {
+			jsonFieldNames(field.Type, names)
+			continue
+		}
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+		if len(name) < 1 {
+			name = field.Name
+		}
+		names[name] = true
+	}
+}
+
+// projectFields keeps only the requested top level fields of a struct response, rejecting
+// names the struct does not have so a typo is not mistaken for an empty field
+func projectFields(data interface{}, fields []string) (interface{}, error) {
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("fields can only select from object responses")
+	}
+	names := map[string]bool{}
+	jsonFieldNames(t, names)
+	unknown := []string{}
+	for i := 0; i < len(fields); i++ {
+		if !names[fields[i]] {
+			unknown = append(unknown, fields[i])
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, errors.New("unknown fields: " + strings.Join(unknown, ","))
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value map[string]interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	projected := map[string]interface{}{}
+	for i := 0; i < len(fields); i++ {
+		if item, exists := value[fields[i]]; exists {
+			projected[fields[i]] = item
+		}
+	}
+	return projected, nil
+}
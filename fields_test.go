@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFieldsProjectTheResponse(t *testing.T) {
+	page := Page{Title: "Post", Uri: "https://example.com/post", Exists: true, WordCount: 120}
+	page.ensureSlices()
+	w := httptest.NewRecorder()
+	writeJson(w, httptest.NewRequest(http.MethodGet, "/?fields=title,links,wordCount&case=snake", nil), page)
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("expected a JSON object, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(body) != 3 || body["title"] != "Post" || body["word_count"] != 120.0 {
+		t.Errorf("expected only the 3 fields, in snake case, got %s", w.Body.String())
+	}
+	if links, isList := body["links"].([]interface{}); !isList || len(links) != 0 {
+		t.Errorf("expected an empty links list, got %v", body["links"])
+	}
+}
+
+func TestUnknownFieldsAreRejected(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeJson(w, httptest.NewRequest(http.MethodGet, "/?fields=title,titel,Exists", nil), Page{})
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "unknown fields: titel,Exists") {
+		t.Errorf("expected a 400 naming the unknown fields, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	writeJson(w, httptest.NewRequest(http.MethodGet, "/?fields=title", nil), []string{"a"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400 for fields of a list response, got %d", w.Code)
+	}
+}
@@ -2,15 +2,17 @@ module scraping/crawler
 
 go 1.17
 
-require gopkg.in/headzoo/surf.v1 v1.0.1
+require (
+	github.com/PuerkitoBio/goquery v1.8.0
+	github.com/go-redis/redis/v8 v8.11.4
+	github.com/gorilla/mux v1.8.0
+	github.com/headzoo/surf v1.0.1
+	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8
+	gopkg.in/headzoo/surf.v1 v1.0.1
+)
 
 require (
-	github.com/PuerkitoBio/goquery v1.8.0 // indirect
 	github.com/andybalholm/cascadia v1.3.1 // indirect
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/go-redis/redis/v8 v8.11.4 // indirect
-	github.com/gorilla/mux v1.8.0 // indirect
-	github.com/headzoo/surf v1.0.1 // indirect
-	golang.org/x/net v0.0.0-20210916014120-12bc252f5db8 // indirect
 )
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/headzoo/surf.v1"
+)
+
+type PageHead struct {
+	Uri          string `json:"uri"`
+	Exists       bool   `json:"exists"`
+	StatusCode   int    `json:"statusCode"`
+	ContentType  string `json:"contentType"`
+	FinalUri     string `json:"finalUri"`
+	LastModified string `json:"lastModified"`
+}
+
+// sendProbeRequest issues a request without reading the body, a GET only asks for the first byte
+func sendProbeRequest(ctx context.Context, client *http.Client, method string, uri string, opts FetchOptions) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	applyHeaders(opts.requestHeaders(), req.Header.Set)
+	for i := 0; i < len(opts.Cookies); i++ {
+		req.AddCookie(opts.Cookies[i])
+	}
+	req.Header.Set("User-Agent", surf.DefaultUserAgent)
+	if method == http.MethodGet {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	// drain at most a little of the body so the connection can be reused
+	io.CopyN(ioutil.Discard, resp.Body, 1024)
+	resp.Body.Close()
+	return resp, nil
+}
+
+// servers that refuse HEAD requests usually answer with one of these
+func headIsRejected(statusCode int) bool {
+	return statusCode == http.StatusMethodNotAllowed || statusCode == http.StatusNotImplemented || statusCode == http.StatusForbidden
+}
+
+func readPageHead(ctx context.Context, uri string, opts FetchOptions) PageHead {
+	head := PageHead{Uri: uri}
+	resp, err := sendProbeRequest(ctx, probeClient, http.MethodHead, uri, opts)
+	if err != nil || headIsRejected(resp.StatusCode) {
+		resp, err = sendProbeRequest(ctx, probeClient, http.MethodGet, uri, opts)
+	}
+	if err != nil {
+		opts.logger().Printf("head failed uri=%s error=%q", uri, err.Error())
+		return head
+	}
+	head.StatusCode = resp.StatusCode
+	head.Exists = resp.StatusCode < 400
+	head.ContentType = resp.Header.Get("Content-Type")
+	head.LastModified = resp.Header.Get("Last-Modified")
+	head.FinalUri = resp.Request.URL.String()
+	return head
+}
+
+func headPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	data := readPageHead(r.Context(), url, fetchOptionsFromRequest(r))
+	writeJson(w, r, data)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProbesHonourMaxRedirects(t *testing.T) {
+	previous := maxRedirects
+	maxRedirects = 1
+	defer func() { maxRedirects = previous }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1":
+			http.Redirect(w, r, "/2", http.StatusFound)
+		case "/2":
+			http.Redirect(w, r, "/3", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			fmt.Fprint(w, "<urlset></urlset>")
+		}
+	}))
+	defer server.Close()
+	if head := readPageHead(context.Background(), server.URL+"/2", FetchOptions{}); !head.Exists || head.FinalUri != server.URL+"/3" {
+		t.Errorf("expected a redirect within the limit to be followed, got %+v", head)
+	}
+	if head := readPageHead(context.Background(), server.URL+"/1", FetchOptions{}); head.Exists {
+		t.Errorf("expected the head check to stop past MAX_REDIRECTS, got %+v", head)
+	}
+	if _, _, err := fetchLimited(context.Background(), server.URL+"/1", time.Second, 1000, FetchOptions{}); err == nil {
+		t.Errorf("expected the sitemap fetch to stop past MAX_REDIRECTS")
+	}
+	maxRedirects = 10
+	if head := readPageHead(context.Background(), server.URL+"/loop", FetchOptions{}); head.Exists {
+		t.Errorf("expected a redirect loop to be rejected, got %+v", head)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type PageSnapshot struct {
+	FetchedAt   string `json:"fetchedAt"`
+	Title       string `json:"title"`
+	WordCount   int    `json:"wordCount"`
+	ContentHash string `json:"contentHash"`
+}
+
+type PageHistory struct {
+	Uri       string         `json:"uri"`
+	Snapshots []PageSnapshot `json:"snapshots"`
+}
+
+// number of snapshots kept per page, 0 disables history
+var historyLength = envInt("HISTORY_LENGTH", 10)
+
+func historyKey(path string) string {
+	return statsCacheKey("history:" + path)
+}
+
+// recordSnapshot prepends the page metadata to its history list, trimming it to HISTORY_LENGTH
+func recordSnapshot(parent context.Context, path string, page Page) error {
+	if historyLength < 1 || !page.Exists {
+		return nil
+	}
+	ctx, cancel := redisContext(parent)
+	defer cancel()
+	snapshot := PageSnapshot{FetchedAt: page.FetchedAt, Title: page.Title, WordCount: page.WordCount, ContentHash: page.ContentHash}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return cacheStore().Push(ctx, historyKey(path), data, historyLength)
+}
+
+// readHistory returns the stored snapshots, newest first
+func readHistory(parent context.Context, path string) ([]PageSnapshot, error) {
+	ctx, cancel := redisContext(parent)
+	defer cancel()
+	snapshots := []PageSnapshot{}
+	items, err := cacheStore().Range(ctx, historyKey(path))
+	if err != nil {
+		return snapshots, err
+	}
+	for i := 0; i < len(items); i++ {
+		var snapshot PageSnapshot
+		if json.Unmarshal(items[i], &snapshot) == nil {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func historyPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	path := normalizePath(vars["url"])
+	snapshots, err := readHistory(r.Context(), path)
+	if err != nil {
+		requestLogger(r).Printf("history read failed path=%s error=%q", path, err.Error())
+		writeError(w, http.StatusServiceUnavailable, "history unavailable")
+		return
+	}
+	data := PageHistory{Uri: scheme + "://" + path, Snapshots: snapshots}
+	writeJson(w, r, data)
+}
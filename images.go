@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gorilla/mux"
+	"github.com/headzoo/surf/browser"
+)
+
+type ImageItem struct {
+	Uri    string `json:"uri"`
+	Alt    string `json:"alt,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+type PageImages struct {
+	Uri    string      `json:"uri"`
+	Exists bool        `json:"exists"`
+	Images []ImageItem `json:"images"`
+}
+
+// lazy-loading plugins park the real source in one of these before swapping it into src
+var lazyImageAttrs = []string{"data-src", "data-lazy-src", "data-original", "data-srcset", "srcset"}
+
+// images at or below this size in both dimensions are assumed to be tracking pixels
+const trackingPixelSize = 2
+
+func imageIsInItems(images []ImageItem, str string) bool {
+	for i := 0; i < len(images); i++ {
+		if images[i].Uri == str {
+			return true
+		}
+	}
+	return false
+}
+
+// pick the widest candidate from a srcset list such as "a.jpg 480w, b.jpg 960w"
+func largestSrcsetItem(srcset string) string {
+	src := ""
+	maxSize := -1.0
+	candidates := strings.Split(srcset, ",")
+	for i := 0; i < len(candidates); i++ {
+		parts := strings.Fields(candidates[i])
+		if len(parts) < 1 {
+			continue
+		}
+		size := 1.0
+		if len(parts) > 1 {
+			descriptor := strings.TrimRight(parts[1], "wx")
+			if val, err := strconv.ParseFloat(descriptor, 64); err == nil {
+				size = val
+			}
+		}
+		if size > maxSize {
+			maxSize = size
+			src = parts[0]
+		}
+	}
+	return src
+}
+
+func extractImageSource(selection *goquery.Selection) string {
+	src := strings.TrimSpace(selection.AttrOr("src", ""))
+	if len(src) > 0 && !strings.HasPrefix(src, "data:") {
+		return src
+	}
+	for i := 0; i < len(lazyImageAttrs); i++ {
+		attr := lazyImageAttrs[i]
+		val := strings.TrimSpace(selection.AttrOr(attr, ""))
+		if strings.HasSuffix(attr, "srcset") {
+			val = largestSrcsetItem(val)
+		}
+		if len(val) > 0 && !strings.HasPrefix(val, "data:") {
+			return val
+		}
+	}
+	return ""
+}
+
+func extractDimension(selection *goquery.Selection, attr string) int {
+	val := strings.TrimSuffix(strings.TrimSpace(selection.AttrOr(attr, "")), "px")
+	num, err := strconv.Atoi(val)
+	if err != nil {
+		return 0
+	}
+	return num
+}
+
+func isTrackingPixel(width int, height int) bool {
+	return width > 0 && height > 0 && width <= trackingPixelSize && height <= trackingPixelSize
+}
+
+func readImages(bow *browser.Browser) []ImageItem {
+	images := []ImageItem{}
+	ogImage, hasOgImage := bow.Find("meta[property='og:image']").First().Attr("content")
+	if hasOgImage {
+		uri, err := bow.ResolveStringUrl(strings.TrimSpace(ogImage))
+		if err == nil && len(ogImage) > 0 {
+			images = append(images, ImageItem{Uri: uri})
+		}
+	}
+	bow.Find("img").Each(func(_ int, s *goquery.Selection) {
+		src := extractImageSource(s)
+		if len(src) < 1 {
+			return
+		}
+		width := extractDimension(s, "width")
+		height := extractDimension(s, "height")
+		if isTrackingPixel(width, height) {
+			return
+		}
+		uri, err := bow.ResolveStringUrl(src)
+		if err == nil && !imageIsInItems(images, uri) {
+			alt := removeSpaces(s.AttrOr("alt", ""))
+			images = append(images, ImageItem{Uri: uri, Alt: alt, Width: width, Height: height})
+		}
+	})
+	return images
+}
+
+func readLiveImages(ctx context.Context, uri string, opts FetchOptions) PageImages {
+	bow, err := fetchPage(ctx, uri, opts)
+	exists := err == nil
+	images := []ImageItem{}
+	if exists {
+		images = readImages(bow)
+	}
+	return PageImages{Uri: uri, Exists: exists, Images: images}
+}
+
+func imagesPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	opts := fetchOptionsFromRequest(r)
+	var data PageImages
+	if !readEndpointCache(r.Context(), "images", url, &data, opts) {
+		data = readLiveImages(r.Context(), url, opts)
+		writeEndpointCache(r.Context(), "images", url, data, opts)
+	}
+	writeJson(w, r, data)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// only lowerCamel keys from struct tags are renamed, so map keys such as upstream header names are kept
+var camelKeyRgx = regexp.MustCompile(`^[a-z][A-Za-z0-9]*$`)
+
+func snakeCase(key string) string {
+	if !camelKeyRgx.MatchString(key) {
+		return key
+	}
+	var sb strings.Builder
+	for _, r := range key {
+		if unicode.IsUpper(r) {
+			sb.WriteRune('_')
+			r = unicode.ToLower(r)
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// snakeCaseKeys renames the object keys of a decoded JSON value recursively
+func snakeCaseKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			renamed[snakeCase(key)] = snakeCaseKeys(item)
+		}
+		return renamed
+	case []interface{}:
+		for i := 0; i < len(v); i++ {
+			v[i] = snakeCaseKeys(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+// toSnakeCase remarshals data with snake_case keys, keeping numbers exactly as encoded
+func toSnakeCase(data interface{}) (interface{}, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return snakeCaseKeys(value), nil
+}
+
+// responseValue applies the ?fields projection and ?case key style requested for a response
+func responseValue(r *http.Request, data interface{}) (interface{}, error) {
+	if fields := queryList(r, "fields"); len(fields) > 0 {
+		projected, err := projectFields(data, fields)
+		if err != nil {
+			return nil, err
+		}
+		data = projected
+	}
+	if strings.EqualFold(r.URL.Query().Get("case"), "snake") {
+		if converted, err := toSnakeCase(data); err == nil {
+			data = converted
+		}
+	}
+	return data, nil
+}
+
+// writeJson encodes a response with the camelCase keys of the struct tags,
+// or with snake_case keys when requested with ?case=snake, indented when requested with ?pretty=1.
+// ?fields=title,links limits the response to the listed top level fields, named as in camelCase.
+// Cached values are always compact and complete, whatever format the response uses
+func writeJson(w http.ResponseWriter, r *http.Request, data interface{}) {
+	data, err := responseValue(r, data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	encoder := json.NewEncoder(w)
+	if queryBool(r, "pretty") {
+		encoder.SetIndent("", "  ")
+	}
+	encoder.Encode(data)
+}
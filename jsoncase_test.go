@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrettyAndCompactResponses(t *testing.T) {
+	page := Page{Title: "Post", Uri: "https://example.com/post", Exists: true}
+	page.ensureSlices()
+	compact := httptest.NewRecorder()
+	writeJson(compact, httptest.NewRequest(http.MethodGet, "/", nil), page)
+	pretty := httptest.NewRecorder()
+	writeJson(pretty, httptest.NewRequest(http.MethodGet, "/?pretty=1", nil), page)
+	if strings.Count(strings.TrimSpace(compact.Body.String()), "\n") != 0 {
+		t.Errorf("expected a single line by default, got %s", compact.Body.String())
+	}
+	if !strings.Contains(pretty.Body.String(), "\n  \"title\": \"Post\",\n") {
+		t.Errorf("expected an indented response with pretty=1, got %s", pretty.Body.String())
+	}
+	if strings.Join(strings.Fields(pretty.Body.String()), "") != strings.Join(strings.Fields(compact.Body.String()), "") {
+		t.Errorf("expected both forms to hold the same JSON")
+	}
+}
+
+func TestCacheValuesRoundTrip(t *testing.T) {
+	page := Page{Title: "Post", Articles: []Article{{Title: "One", Content: "<p>Line\none</p>"}}}
+	for _, compress := range []bool{false, true} {
+		previous := cacheCompress
+		cacheCompress = compress
+		encoded, err := encodeCacheValue(page)
+		cacheCompress = previous
+		if err != nil {
+			t.Fatalf("encode failed: %v", err)
+		}
+		if !compress && strings.Contains(string(encoded), "\n") {
+			t.Errorf("expected a compact cache value, got %s", encoded)
+		}
+		var decoded Page
+		if err := decodeCacheValue(encoded, &decoded); err != nil || decoded.Title != "Post" || decoded.Articles[0].Content != page.Articles[0].Content {
+			t.Errorf("expected the page back with compress=%v, got %+v, %v", compress, decoded, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "strings"
+
+func normalizeWord(word string) string {
+	return strings.ToLower(strings.Trim(word, ".,;:!?\"'()[]"))
+}
+
+// countPhrase counts case-insensitive occurrences of a word or multi-word phrase in a word list
+func countPhrase(words []string, phrase string) int {
+	terms := strings.Fields(phrase)
+	for i := 0; i < len(terms); i++ {
+		terms[i] = normalizeWord(terms[i])
+	}
+	if len(terms) < 1 {
+		return 0
+	}
+	normalized := make([]string, len(words))
+	for i := 0; i < len(words); i++ {
+		normalized[i] = normalizeWord(words[i])
+	}
+	count := 0
+	for i := 0; i+len(terms) <= len(normalized); i++ {
+		matched := true
+		for j := 0; j < len(terms); j++ {
+			if normalized[i+j] != terms[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			count++
+		}
+	}
+	return count
+}
+
+// addKeywordCounts reports how often the keyword occurs and its density per thousand words
+func (ps *PageStats) addKeywordCounts(words []string, keyword string) PageStats {
+	occurrences := countPhrase(words, keyword)
+	density := 0
+	if len(words) > 0 {
+		density = (occurrences*1000 + len(words)/2) / len(words)
+	}
+	ps.addCountItem("keywordOccurrences", occurrences)
+	ps.addCountItem("keywordDensityPerMille", density)
+	return *ps
+}
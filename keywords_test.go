@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAddKeywordCountsForAWord(t *testing.T) {
+	words := strings.Fields("Go is fun. I like go, and GO (the game) too; going out is not go-ing.")
+	ps := PageStats{}
+	ps.addKeywordCounts(words, "go")
+	if occurrences := countValue(ps, "keywordOccurrences"); occurrences != 3 {
+		t.Errorf("expected 3 occurrences of go, got %d", occurrences)
+	}
+	// 3 of 16 words, rounded to the nearest per mille
+	if density := countValue(ps, "keywordDensityPerMille"); len(words) != 16 || density != 188 {
+		t.Errorf("expected a density of 188 per mille, got %d of %d words", density, len(words))
+	}
+}
+
+func TestAddKeywordCountsForAPhrase(t *testing.T) {
+	words := strings.Fields("Open source matters. open  SOURCE code, \"open source\" and source open.")
+	ps := PageStats{}
+	ps.addKeywordCounts(words, " Open   Source ")
+	if occurrences := countValue(ps, "keywordOccurrences"); occurrences != 3 {
+		t.Errorf("expected 3 occurrences of the phrase, got %d", occurrences)
+	}
+	empty := PageStats{}
+	empty.addKeywordCounts([]string{}, "open source")
+	if countValue(empty, "keywordOccurrences") != 0 || countValue(empty, "keywordDensityPerMille") != 0 {
+		t.Errorf("expected no occurrences in an empty page, got %+v", empty.Counts)
+	}
+}
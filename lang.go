@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/headzoo/surf/browser"
+)
+
+// pages with fewer words than this are too short to tell their language from the text
+const langDetectMinWords = 20
+
+// detectTextLanguage guesses the language of text from the share of each language's stopwords.
+// The guess is certain when stopwords make up a fair share of the text and one language clearly leads
+func detectTextLanguage(text string) (string, bool) {
+	words := strings.Fields(strings.ToLower(text))
+	if len(words) < langDetectMinWords {
+		return "", false
+	}
+	langs := []string{}
+	for lang := range builtinStopwords {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	best, bestHits, nextHits := "", 0, 0
+	for i := 0; i < len(langs); i++ {
+		stopwords := stopwordsFor(langs[i])
+		hits := 0
+		for j := 0; j < len(words); j++ {
+			if stopwords[strings.Trim(words[j], ".,;:!?\"'()«»“”")] {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits, nextHits = langs[i], hits, bestHits
+		} else if hits > nextHits {
+			nextHits = hits
+		}
+	}
+	if bestHits < 1 {
+		return "", false
+	}
+	certain := bestHits*10 >= len(words) && bestHits*2 >= nextHits*3
+	return best, certain
+}
+
+// readLanguage returns the primary language of the page from <html lang>, checked against the
+// body text. It is uncertain when the page declares none and the text is inconclusive, or when
+// the text clearly reads as another language than the one declared, which is then returned
+func readLanguage(bow *browser.Browser, bodyText string) (string, bool) {
+	declared := strings.TrimSpace(bow.Find("html").AttrOr("lang", ""))
+	detected, certain := detectTextLanguage(bodyText)
+	if len(declared) > 0 {
+		declared = stopwordLang(declared)
+		if !certain || detected == declared {
+			return declared, true
+		}
+		return detected, false
+	}
+	return detected, certain
+}
+
+// requireLanguage empties the page when its language is known not to match lang, so monolingual
+// crawls can drop it. Pages whose language is uncertain are kept and flagged instead
+func (p *Page) requireLanguage(lang string) {
+	if len(strings.TrimSpace(lang)) < 1 || !p.Exists {
+		return
+	}
+	if len(p.Lang) < 1 {
+		p.LangUncertain = true
+		return
+	}
+	if stopwordLang(p.Lang) == stopwordLang(lang) || p.LangUncertain {
+		return
+	}
+	p.LangMismatch = true
+	p.Articles = []Article{}
+	p.Links = []LinkItem{}
+	p.Warnings = append(p.Warnings, fmt.Sprintf("page language %s does not match the required %s", p.Lang, stopwordLang(lang)))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const englishArticle = `<html lang="en-GB"><body><article><h2><a href="/walk">A walk by the river</a></h2>
+<p>We walked along the river in the morning and then we stopped at the old mill, where the water was
+still and the light was soft. It was the best part of the day and we will go back there again.</p></article></body></html>`
+
+const frenchArticle = `<html lang="fr"><body><article><h2><a href="/balade">Une balade au bord de la rivière</a></h2>
+<p>Nous avons marché le long de la rivière le matin et nous nous sommes arrêtés au vieux moulin, où
+l'eau était calme et la lumière était douce. Ce fut le meilleur moment de la journée et nous y retournerons.</p></article></body></html>`
+
+func TestDeclaredLanguageIsCheckedAgainstTheText(t *testing.T) {
+	bow := openHtml(t, englishArticle)
+	if lang, certain := readLanguage(bow, readBodyText(bow)); lang != "en" || !certain {
+		t.Errorf("expected a certain en, got %q (certain %v)", lang, certain)
+	}
+	bow = openHtml(t, strings.Replace(englishArticle, `lang="en-GB"`, `lang="fr"`, 1))
+	if lang, certain := readLanguage(bow, readBodyText(bow)); lang != "en" || certain {
+		t.Errorf("expected the detected en flagged as uncertain, got %q (certain %v)", lang, certain)
+	}
+}
+
+func TestRequiredLanguageKeepsMatchingPages(t *testing.T) {
+	server := serveHtml(t, frenchArticle)
+	page := readLiveBlogPage(context.Background(), server.URL+"/", FetchOptions{})
+	page.requireLanguage("fr-CA")
+	if page.LangMismatch || page.Lang != "fr" || len(page.Articles) != 1 || len(page.Warnings) > 0 {
+		t.Errorf("expected the French page kept, got lang %q with %d articles and warnings %q", page.Lang, len(page.Articles), page.Warnings)
+	}
+}
+
+func TestRequiredLanguageEmptiesMismatchingPages(t *testing.T) {
+	server := serveHtml(t, englishArticle)
+	page := readLiveBlogPage(context.Background(), server.URL+"/", FetchOptions{})
+	page.requireLanguage("fr")
+	if !page.LangMismatch || len(page.Articles) != 0 || len(page.Links) != 0 || len(page.Warnings) != 1 {
+		t.Errorf("expected the English page emptied with a warning, got %d articles, %d links and warnings %q", len(page.Articles), len(page.Links), page.Warnings)
+	}
+	uncertain := Page{Exists: true}
+	uncertain.requireLanguage("fr")
+	if uncertain.LangMismatch || !uncertain.LangUncertain {
+		t.Errorf("expected a page of unknown language kept and flagged")
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+// semantic containers links are grouped by, links outside all of them are grouped under body
+const linkContainerSelector = "nav,header,footer,article,aside,main"
+
+// linkContainerKey names the nearest semantic container of a link by its tag, id and classes,
+// e.g. nav#primary.menu, so two navigation blocks stay apart
+func linkContainerKey(link *goquery.Selection) string {
+	container := link.Closest(linkContainerSelector)
+	if container.Length() < 1 {
+		return "body"
+	}
+	set := buildClassesIdSetWithin(container, 0)
+	return set.ToPath()
+}
+
+// groupLinks lists the distinct link paths of the page by their nearest semantic container,
+// as Links does for the page as a whole
+func groupLinks(bow *browser.Browser) map[string][]LinkItem {
+	groups := map[string][]LinkItem{}
+	bow.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		uri, err := bow.ResolveStringUrl(strings.TrimSpace(s.AttrOr("href", "")))
+		if err != nil {
+			return
+		}
+		linkUrl, err := url.Parse(uri)
+		if err != nil || len(linkUrl.Path) < 1 {
+			return
+		}
+		key := linkContainerKey(s)
+		if !uriIsInLinkItems(groups[key], linkUrl.Path) {
+			groups[key] = append(groups[key], makeLinkItem(linkUrl.Path, s.Text()))
+		}
+	})
+	return groups
+}
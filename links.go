@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/headzoo/surf/browser"
+	"golang.org/x/net/publicsuffix"
+)
+
+// INTERNAL_LINKS=domain counts links to any host of the same registrable domain as internal,
+// e.g. blog.example.com to www.example.com, the default host only matches the exact host
+var internalLinksByDomain = strings.EqualFold(envString("INTERNAL_LINKS", "host"), "domain")
+
+// link texts longer than this many characters, e.g. of links wrapping a whole teaser, are cut with an ellipsis
+var linkTitleMaxChars = envInt("LINK_TITLE_MAX_CHARS", 120)
+
+// makeLinkItem normalises the spacing of the link text and caps its length
+func makeLinkItem(uri string, text string) LinkItem {
+	title := strings.Join(strings.Fields(text), " ")
+	length := utf8.RuneCountInString(title)
+	if linkTitleMaxChars < 1 || length <= linkTitleMaxChars {
+		return LinkItem{Uri: uri, Title: title}
+	}
+	runes := []rune(title)
+	title = strings.TrimSpace(string(runes[:linkTitleMaxChars])) + truncationEllipsis
+	return LinkItem{Uri: uri, Title: title, TitleLength: length}
+}
+
+func isWebUrl(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// isInternalHost compares hosts without the port or letter case
+func isInternalHost(linkUrl *url.URL, pageUrl *url.URL) bool {
+	return strings.EqualFold(linkUrl.Hostname(), pageUrl.Hostname())
+}
+
+// registrableDomain returns the eTLD+1 of host, e.g. example.co.uk for www.example.co.uk,
+// or the host itself for ip addresses and hosts without one
+func registrableDomain(host string) string {
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(host))
+	if err != nil {
+		return strings.ToLower(host)
+	}
+	return domain
+}
+
+// isInternalLink compares hosts as isInternalHost, or by registrable domain with INTERNAL_LINKS=domain.
+// Crawls keep to the exact host either way
+func isInternalLink(linkUrl *url.URL, pageUrl *url.URL) bool {
+	if isInternalHost(linkUrl, pageUrl) {
+		return true
+	}
+	return internalLinksByDomain && registrableDomain(linkUrl.Hostname()) == registrableDomain(pageUrl.Hostname())
+}
+
+// countLinkDestinations splits the resolved web links of a page into internal and external ones,
+// mailto: and other non-web links are not counted
+func countLinkDestinations(bow *browser.Browser) (internal int, external int) {
+	pageUrl := bow.Url()
+	if pageUrl == nil {
+		return
+	}
+	links := bow.Links()
+	for i := 0; i < len(links); i++ {
+		linkUrl := links[i].Url()
+		if !isWebUrl(linkUrl) {
+			continue
+		}
+		if isInternalLink(linkUrl, pageUrl) {
+			internal++
+		} else {
+			external++
+		}
+	}
+	return
+}
+
+// percentOf returns part as a whole-number percentage of total
+func percentOf(part int, total int) int {
+	if total < 1 {
+		return 0
+	}
+	return (part*100 + total/2) / total
+}
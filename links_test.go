@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinksResolveAgainstTheBaseHref(t *testing.T) {
+	bow := openHtml(t, `<html><head><base href="/blog/"></head><body>
+<nav><a href="post">Post</a><a href="/about">About</a></nav>
+</body></html>`)
+	links := pageWebLinks(bow, bow.Url().String())
+	if len(links) != 2 || !strings.HasSuffix(links[0], "/blog/post") || !strings.HasSuffix(links[1], "/about") {
+		t.Errorf("expected post under the base href and about from the root, got %v", links)
+	}
+}
+
+func TestMakeLinkItemCapsOversizedAnchors(t *testing.T) {
+	previous := linkTitleMaxChars
+	linkTitleMaxChars = 20
+	defer func() { linkTitleMaxChars = previous }()
+	teaser := "  Read more:\n\t" + strings.Repeat("très long teaser ", 10)
+	item := makeLinkItem("/post", teaser)
+	if item.Title != "Read more: très long…" || item.TitleLength != 180 {
+		t.Errorf("expected the spacing normalised and the title cut at 20 characters, got %q of %d", item.Title, item.TitleLength)
+	}
+	if short := makeLinkItem("/about", " About\nus "); short.Title != "About us" || short.TitleLength != 0 {
+		t.Errorf("expected a short title to be kept whole, got %+v", short)
+	}
+}
+
+const navAndArticle = `<html><body>
+<nav id="primary" class="menu"><a href="/">Home</a><a href="/about">About</a><a href="/about#team">Team</a></nav>
+<article><h2><a href="/first-post">First post</a></h2><p>Text with a <a href="/about">link</a>.</p></article>
+<p><a href="/contact">Contact</a></p>
+</body></html>`
+
+func TestGroupLinksByTheirContainer(t *testing.T) {
+	groups := groupLinks(openHtml(t, navAndArticle))
+	if len(groups) != 3 {
+		t.Fatalf("expected nav, article and body groups, got %v", groups)
+	}
+	nav := groups["nav#primary.menu"]
+	if len(nav) != 2 || nav[0].Uri != "/" || nav[1].Uri != "/about" {
+		t.Errorf("expected the nav links once each by path, got %v", nav)
+	}
+	article := groups["article"]
+	if len(article) != 2 || article[0].Uri != "/first-post" || article[1].Uri != "/about" {
+		t.Errorf("expected the article links apart from the nav, got %v", article)
+	}
+	if body := groups["body"]; len(body) != 1 || body[0].Uri != "/contact" {
+		t.Errorf("expected the link outside all containers under body, got %v", body)
+	}
+}
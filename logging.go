@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+)
+
+type contextKey string
+
+const loggerContextKey = contextKey("logger")
+
+const requestIdHeader = "X-Request-ID"
+
+// client supplied ids are accepted when short and free of spaces or control characters
+var validRequestIdRgx = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+var defaultLogger = log.New(os.Stderr, "", log.LstdFlags)
+
+// newRequestId returns a random version 4 UUID
+func newRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// newRequestLogger writes where the default logger does, tagging each line with the request id
+func newRequestLogger(requestId string) *log.Logger {
+	return log.New(defaultLogger.Writer(), "requestId="+requestId+" ", log.LstdFlags|log.Lmsgprefix)
+}
+
+// requestLogger returns the logger bound to the request, tagged with its request id
+func requestLogger(r *http.Request) *log.Logger {
+	if logger, ok := r.Context().Value(loggerContextKey).(*log.Logger); ok {
+		return logger
+	}
+	return defaultLogger
+}
+
+// requestIdMiddleware tags each request with an id, reusing a valid X-Request-ID from the client
+func requestIdMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(requestIdHeader)
+		if !validRequestIdRgx.MatchString(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+		logger := newRequestLogger(requestId)
+		// only the path is logged as query params may carry cookie values
+		logger.Printf("request method=%s path=%s", r.Method, r.URL.EscapedPath())
+		ctx := context.WithValue(r.Context(), loggerContextKey, logger)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/headzoo/surf/browser"
+)
+
+func TestMain(m *testing.M) {
+	// fetch and cache logs would drown the test output
+	defaultLogger.SetOutput(ioutil.Discard)
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// serveHtml answers every request with html
+func serveHtml(t *testing.T, html string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		fmt.Fprint(w, html)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// openHtml fetches html served by a test server, so the browser holds a page url as for a live fetch
+func openHtml(t *testing.T, html string) *browser.Browser {
+	server := serveHtml(t, html)
+	bow, err := fetchPage(context.Background(), server.URL+"/", FetchOptions{})
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	return bow
+}
+
+// getRoute serves a GET request for path through the service router
+func getRoute(path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	return w
+}
+
+// blogRoute returns the /blog path reading uri, a test server url, over http
+func blogRoute(uri string, cacheMode string) string {
+	return "/blog/" + url.PathEscape(strings.TrimPrefix(uri, "http://")) + "/http/" + cacheMode
+}
+
+// countValue returns the discover count under key, or -1 when it is missing
+func countValue(ps PageStats, key string) int {
+	if i := findCountItemIndex(key, ps.Counts); i >= 0 {
+		return ps.Counts[i].Value
+	}
+	return -1
+}
+
+// useMemoryCache swaps the cache for an empty in-memory one until the test ends
+func useMemoryCache(t *testing.T) *memoryBackend {
+	previous := cacheStore()
+	memory := newMemoryBackend(100)
+	activeCache = memory
+	t.Cleanup(func() {
+		activeCache = previous
+	})
+	return memory
+}
+
+// withoutRateLimit lets polite fetches of a test run back to back
+func withoutRateLimit(t *testing.T) {
+	previous := hostRateLimiter
+	hostRateLimiter = newHostLimiter(0)
+	t.Cleanup(func() {
+		hostRateLimiter = previous
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+type MediaItem struct {
+	Type   string `json:"type"`
+	Uri    string `json:"uri"`
+	Poster string `json:"poster,omitempty"`
+}
+
+// hosts of embedded players mapped to the media type they serve, subdomains also match
+var embedPlayerHosts = map[string]string{
+	"youtube.com":          "video",
+	"youtube-nocookie.com": "video",
+	"vimeo.com":            "video",
+	"dailymotion.com":      "video",
+	"soundcloud.com":       "audio",
+	"spotify.com":          "audio",
+}
+
+func embedPlayerType(host string) string {
+	host = strings.ToLower(host)
+	for playerHost, mediaType := range embedPlayerHosts {
+		if host == playerHost || strings.HasSuffix(host, "."+playerHost) {
+			return mediaType
+		}
+	}
+	return ""
+}
+
+// normalizeVideoUri maps YouTube and Vimeo embed urls to their canonical watch page,
+// other providers keep the embed url
+func normalizeVideoUri(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	switch {
+	case host == "youtu.be" && len(segments[0]) > 0:
+		return "https://www.youtube.com/watch?v=" + segments[0]
+	case embedPlayerType(host) == "video" && strings.Contains(host, "youtube"):
+		if len(segments) > 1 && (segments[0] == "embed" || segments[0] == "v" || segments[0] == "shorts") {
+			return "https://www.youtube.com/watch?v=" + segments[1]
+		}
+		if id := parsed.Query().Get("v"); len(id) > 0 {
+			return "https://www.youtube.com/watch?v=" + id
+		}
+	case host == "player.vimeo.com" && len(segments) > 1 && segments[0] == "video":
+		return "https://vimeo.com/" + segments[1]
+	}
+	return uri
+}
+
+// readEmbeddedVideos returns the normalised urls of the video player iframes inside selection
+func readEmbeddedVideos(bow *browser.Browser, selection *goquery.Selection) []string {
+	videos := []string{}
+	selection.Find("iframe").Each(func(_ int, s *goquery.Selection) {
+		uri := resolveMediaUri(bow, s.AttrOr("src", s.AttrOr("data-src", "")))
+		if len(uri) < 1 {
+			return
+		}
+		parsed, err := url.Parse(uri)
+		if err != nil || embedPlayerType(parsed.Hostname()) != "video" {
+			return
+		}
+		uri = normalizeVideoUri(uri)
+		if !stringInList(videos, uri) {
+			videos = append(videos, uri)
+		}
+	})
+	return videos
+}
+
+func mediaIsInItems(items []MediaItem, uri string) bool {
+	for i := 0; i < len(items); i++ {
+		if items[i].Uri == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveMediaUri returns the absolute uri of a media attribute or an empty string
+func resolveMediaUri(bow *browser.Browser, value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) < 1 || strings.HasPrefix(value, "data:") || strings.HasPrefix(value, "blob:") {
+		return ""
+	}
+	uri, err := bow.ResolveStringUrl(value)
+	if err != nil {
+		return ""
+	}
+	return uri
+}
+
+// readMedia lists the video and audio sources and embedded players of a page,
+// it must run before article extraction strips media elements from the document
+func readMedia(bow *browser.Browser) []MediaItem {
+	items := []MediaItem{}
+	bow.Find("video,audio").Each(func(_ int, s *goquery.Selection) {
+		mediaType := goquery.NodeName(s)
+		poster := resolveMediaUri(bow, s.AttrOr("poster", ""))
+		sources := []string{s.AttrOr("src", "")}
+		s.Find("source").Each(func(_ int, source *goquery.Selection) {
+			sources = append(sources, source.AttrOr("src", ""))
+		})
+		for i := 0; i < len(sources); i++ {
+			uri := resolveMediaUri(bow, sources[i])
+			if len(uri) > 0 && !mediaIsInItems(items, uri) {
+				items = append(items, MediaItem{Type: mediaType, Uri: uri, Poster: poster})
+			}
+		}
+	})
+	bow.Find("iframe").Each(func(_ int, s *goquery.Selection) {
+		uri := resolveMediaUri(bow, s.AttrOr("src", s.AttrOr("data-src", "")))
+		if len(uri) < 1 {
+			return
+		}
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return
+		}
+		if mediaType := embedPlayerType(parsed.Hostname()); len(mediaType) > 0 && !mediaIsInItems(items, uri) {
+			items = append(items, MediaItem{Type: mediaType, Uri: uri})
+		}
+	})
+	return items
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestArticleVideosFromYouTubeAndVimeoEmbeds(t *testing.T) {
+	bow := openHtml(t, `<html><body>
+<article><h2><a href="/clips">Clips</a></h2>
+<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ?rel=0&autoplay=1"></iframe>
+<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"></iframe>
+<iframe data-src="//player.vimeo.com/video/76979871?h=8272103f6e"></iframe>
+<iframe src="https://w.soundcloud.com/player/?url=track"></iframe>
+<iframe src="https://example.com/widget"></iframe>
+<p>Some clips.</p></article>
+</body></html>`)
+	articles, _, _ := readBlogArticles(context.Background(), bow, ExtractOptions{})
+	if len(articles) != 1 {
+		t.Fatalf("expected an article, got %d", len(articles))
+	}
+	expected := "https://www.youtube.com/watch?v=dQw4w9WgXcQ|https://vimeo.com/76979871"
+	if videos := strings.Join(articles[0].Videos, "|"); videos != expected {
+		t.Errorf("expected %s, got %s", expected, videos)
+	}
+	if strings.Contains(articles[0].Content, "iframe") {
+		t.Errorf("expected the players to be stripped from the content")
+	}
+}
+
+func TestNormalizeVideoUri(t *testing.T) {
+	cases := map[string]string{
+		"https://youtu.be/abc123":                        "https://www.youtube.com/watch?v=abc123",
+		"https://m.youtube.com/watch?v=abc123&t=30":      "https://www.youtube.com/watch?v=abc123",
+		"https://www.youtube.com/shorts/abc123":          "https://www.youtube.com/watch?v=abc123",
+		"https://player.vimeo.com/video/42":              "https://vimeo.com/42",
+		"https://www.dailymotion.com/embed/video/x7tgad": "https://www.dailymotion.com/embed/video/x7tgad",
+	}
+	for uri, expected := range cases {
+		if normalized := normalizeVideoUri(uri); normalized != expected {
+			t.Errorf("expected %s for %s, got %s", expected, uri, normalized)
+		}
+	}
+}
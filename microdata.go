@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+	"golang.org/x/net/html"
+)
+
+// MicrodataItem follows the JSON form of the microdata spec, property values are
+// strings or nested items
+type MicrodataItem struct {
+	Type       []string                 `json:"type"`
+	Id         string                   `json:"id,omitempty"`
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+// nested items deeper than this are reduced to their text, guarding against itemref cycles
+const maxMicrodataDepth = 8
+
+// microdataTypes splits itemtype, which may list several urls of one vocabulary
+func microdataTypes(selection *goquery.Selection) []string {
+	return strings.Fields(selection.AttrOr("itemtype", ""))
+}
+
+// microdataValue reads a property value from the attribute the microdata spec assigns to the element
+func microdataValue(bow *browser.Browser, selection *goquery.Selection) string {
+	switch goquery.NodeName(selection) {
+	case "meta":
+		return strings.TrimSpace(selection.AttrOr("content", ""))
+	case "a", "area", "link":
+		return resolveMicrodataUrl(bow, selection.AttrOr("href", ""))
+	case "img", "audio", "video", "source", "iframe", "embed", "track":
+		return resolveMicrodataUrl(bow, selection.AttrOr("src", ""))
+	case "object":
+		return resolveMicrodataUrl(bow, selection.AttrOr("data", ""))
+	case "time":
+		if datetime, exists := selection.Attr("datetime"); exists {
+			return strings.TrimSpace(datetime)
+		}
+	case "data", "meter":
+		return strings.TrimSpace(selection.AttrOr("value", ""))
+	}
+	return removeSpaces(strings.TrimSpace(selection.Text()))
+}
+
+func resolveMicrodataUrl(bow *browser.Browser, href string) string {
+	href = strings.TrimSpace(href)
+	if len(href) < 1 {
+		return ""
+	}
+	uri, err := bow.ResolveStringUrl(href)
+	if err != nil {
+		return ""
+	}
+	return uri
+}
+
+// itemProperties collects the itemprop elements of item as the microdata spec does,
+// searching its children and the elements named by itemref without entering nested items
+func itemProperties(bow *browser.Browser, item *goquery.Selection) []*goquery.Selection {
+	properties := []*goquery.Selection{}
+	pending := []*goquery.Selection{}
+	item.Children().Each(func(_ int, s *goquery.Selection) {
+		pending = append(pending, s)
+	})
+	for _, id := range strings.Fields(item.AttrOr("itemref", "")) {
+		ref := bow.Find("[id='" + strings.Replace(id, "'", "\\'", -1) + "']").First()
+		if ref.Length() > 0 {
+			pending = append(pending, ref)
+		}
+	}
+	seen := map[*html.Node]bool{item.Get(0): true}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+		if seen[current.Get(0)] {
+			continue
+		}
+		seen[current.Get(0)] = true
+		if _, isProperty := current.Attr("itemprop"); isProperty {
+			properties = append(properties, current)
+		}
+		if _, isItem := current.Attr("itemscope"); !isItem {
+			current.Children().Each(func(_ int, s *goquery.Selection) {
+				pending = append(pending, s)
+			})
+		}
+	}
+	return properties
+}
+
+// readMicrodataItem reads the properties of an item, with nested items as nested maps
+func readMicrodataItem(bow *browser.Browser, item *goquery.Selection, depth int) MicrodataItem {
+	data := MicrodataItem{Type: microdataTypes(item), Id: strings.TrimSpace(item.AttrOr("itemid", "")), Properties: map[string][]interface{}{}}
+	properties := itemProperties(bow, item)
+	for i := 0; i < len(properties); i++ {
+		s := properties[i]
+		var value interface{}
+		if _, nested := s.Attr("itemscope"); nested && depth < maxMicrodataDepth {
+			value = readMicrodataItem(bow, s, depth+1)
+		} else if nested {
+			value = removeSpaces(strings.TrimSpace(s.Text()))
+		} else {
+			value = microdataValue(bow, s)
+		}
+		for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+			data.Properties[name] = append(data.Properties[name], value)
+		}
+	}
+	return data
+}
+
+// readMicrodata returns the top level items declared with itemscope, those that are not
+// themselves the property of another item
+func readMicrodata(bow *browser.Browser) []MicrodataItem {
+	items := []MicrodataItem{}
+	bow.Find("[itemscope]:not([itemprop])").Each(func(_ int, s *goquery.Selection) {
+		items = append(items, readMicrodataItem(bow, s, 1))
+	})
+	return items
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+const blogPostingMicrodata = `<html><body>
+<article itemscope itemtype="https://schema.org/BlogPosting" itemref="byline">
+<h1 itemprop="headline"><a href="/posts/tides">Reading the tides</a></h1>
+<time itemprop="datePublished" datetime="2024-05-02T08:30:00+02:00">2 May</time>
+<meta itemprop="wordCount" content="820">
+<link itemprop="mainEntityOfPage" href=" /posts/tides ">
+<img itemprop="image" src="/img/tides.jpg" alt="Low tide">
+<div itemprop="publisher" itemscope itemtype="https://schema.org/Organization">
+<span itemprop="name">Coast   Notes</span>
+</div>
+<p itemprop="articleBody">Tides follow the moon, mostly.</p>
+</article>
+<p id="byline">By <span itemprop="author" itemscope itemtype="https://schema.org/Person"><span itemprop="name">Ana Silva</span></span></p>
+</body></html>`
+
+func TestReadMicrodataBlogPosting(t *testing.T) {
+	bow := openHtml(t, blogPostingMicrodata)
+	base := bow.Url().Scheme + "://" + bow.Url().Host
+	items := readMicrodata(bow)
+	if len(items) != 1 || len(items[0].Type) != 1 || items[0].Type[0] != "https://schema.org/BlogPosting" {
+		t.Fatalf("expected a single BlogPosting, got %+v", items)
+	}
+	props := items[0].Properties
+	expected := map[string]string{
+		"headline":         "Reading the tides",
+		"datePublished":    "2024-05-02T08:30:00+02:00",
+		"wordCount":        "820",
+		"mainEntityOfPage": base + "/posts/tides",
+		"image":            base + "/img/tides.jpg",
+		"articleBody":      "Tides follow the moon, mostly.",
+	}
+	for name, value := range expected {
+		if len(props[name]) != 1 || props[name][0] != value {
+			t.Errorf("expected %s to be %q, got %v", name, value, props[name])
+		}
+	}
+	publisher, isItem := props["publisher"][0].(MicrodataItem)
+	if !isItem || publisher.Properties["name"][0] != "Coast Notes" {
+		t.Errorf("expected the publisher as a nested item, got %+v", props["publisher"])
+	}
+	author, isItem := props["author"][0].(MicrodataItem)
+	if !isItem || author.Type[0] != "https://schema.org/Person" || author.Properties["name"][0] != "Ana Silva" {
+		t.Errorf("expected the author through itemref, got %+v", props["author"])
+	}
+}
+
+func TestBlogPostingDateIsTheArticleDate(t *testing.T) {
+	articles, _, _ := readBlogArticles(context.Background(), openHtml(t, blogPostingMicrodata), ExtractOptions{})
+	if len(articles) != 1 || articles[0].Published != "2024-05-02T06:30:00Z" {
+		t.Errorf("expected the datePublished in UTC, got %+v", articles)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// wantsNdjson checks for ?format=ndjson, which streams one JSON object per line as results complete
+func wantsNdjson(r *http.Request) bool {
+	return strings.EqualFold(r.URL.Query().Get("format"), "ndjson")
+}
+
+// ndjsonWriter writes each value on its own line and flushes it straight away,
+// applying the same ?fields and ?case options as writeJson
+type ndjsonWriter struct {
+	r       *http.Request
+	encoder *json.Encoder
+	flusher http.Flusher
+}
+
+// newNdjsonWriter checks the response options against sample, a value of the type to be streamed,
+// and writes a 400 error instead when they are invalid
+func newNdjsonWriter(w http.ResponseWriter, r *http.Request, sample interface{}) (*ndjsonWriter, bool) {
+	if _, err := responseValue(r, sample); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=UTF-8")
+	flusher, _ := w.(http.Flusher)
+	return &ndjsonWriter{r: r, encoder: json.NewEncoder(w), flusher: flusher}, true
+}
+
+func (nw *ndjsonWriter) write(data interface{}) {
+	value, err := responseValue(nw.r, data)
+	if err != nil {
+		return
+	}
+	nw.encoder.Encode(value)
+	if nw.flusher != nil {
+		nw.flusher.Flush()
+	}
+}
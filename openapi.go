@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+type apiRoute struct {
+	// Method defaults to get
+	Method   string
+	Path     string
+	Summary  string
+	Params   []string
+	Query    []string
+	Response interface{}
+}
+
+// query params accepted by every route that fetches a page
+var fetchQueryParams = []string{"header", "cookie", "lang"}
+
+// query params accepted by every route that writes JSON
+var responseQueryParams = []string{"case", "pretty", "fields"}
+
+// query params accepted by routes that extract articles
+var extractQueryParams = append([]string{"segment", "titleSelectors", "timings", "timing", "headers", "minWords", "paginate", "partial", "maxContentBytes", "maxContentChars", "dedupe", "sanitize", "noscript", "mode", "retryEmpty", "render", "comments", "maxLinks", "since", "until", "undated", "minTitleWords", "minTitleChars", "altText", "requireLang", "offset", "limit", "groupLinks"}, fetchQueryParams...)
+
+// query params accepted by the discover routes
+var discoverQueryParams = append([]string{"tags", "keyword", "noscript", "altText"}, fetchQueryParams...)
+
+// query params accepted by the crawl routes
+var crawlQueryParams = append([]string{"maxPages", "format"}, fetchQueryParams...)
+
+// query params accepted by the outline routes
+var outlineQueryParams = append([]string{"depth", "minNodeWords"}, fetchQueryParams...)
+
+// apiRoutes documents the public routes registered in handleRequests
+var apiRoutes = []apiRoute{
+	{Path: "/info", Summary: "List available routes", Response: map[string]interface{}{}},
+	{Path: "/blog/{url}/{scheme}/{cacheMode}", Summary: "Extract the title, articles and links of a page", Params: []string{"url", "scheme", "cacheMode"}, Query: extractQueryParams, Response: Page{}},
+	{Path: "/blog/{url}/{cacheMode}", Summary: "Extract a page using the default scheme", Params: []string{"url", "cacheMode"}, Query: extractQueryParams, Response: Page{}},
+	{Path: "/discover/{url}/{scheme}", Summary: "Analyse page structure and word counts", Params: []string{"url", "scheme"}, Query: discoverQueryParams, Response: PageStats{}},
+	{Path: "/discover/{url}", Summary: "Analyse a page using the default scheme", Params: []string{"url"}, Query: discoverQueryParams, Response: PageStats{}},
+	{Path: "/images/{url}/{scheme}", Summary: "List the images referenced by a page", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: PageImages{}},
+	{Path: "/images/{url}", Summary: "List images using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: PageImages{}},
+	{Path: "/head/{url}/{scheme}", Summary: "Check a page is reachable without parsing it", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: PageHead{}},
+	{Path: "/head/{url}", Summary: "Check reachability using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: PageHead{}},
+	{Path: "/expand/{url}/{scheme}", Summary: "Follow the redirects of a shortened link", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: ExpandedLink{}},
+	{Path: "/expand/{url}", Summary: "Expand a link using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: ExpandedLink{}},
+	{Path: "/tables/{url}/{scheme}", Summary: "Extract the headers and rows of each table", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: PageTables{}},
+	{Path: "/tables/{url}", Summary: "Extract tables using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: PageTables{}},
+	{Path: "/history/{url}/{scheme}", Summary: "List recent crawl snapshots of a page", Params: []string{"url", "scheme"}, Response: PageHistory{}},
+	{Path: "/history/{url}", Summary: "List snapshots using the default scheme", Params: []string{"url"}, Response: PageHistory{}},
+	{Path: "/crawl/{url}/{scheme}", Summary: "Fetch pages on the same host breadth first, within a page budget", Params: []string{"url", "scheme"}, Query: crawlQueryParams, Response: CrawlResult{}},
+	{Path: "/crawl/{url}", Summary: "Crawl a site using the default scheme", Params: []string{"url"}, Query: crawlQueryParams, Response: CrawlResult{}},
+	{Path: "/count/{url}/{scheme}", Summary: "Count the words, links and images of a page with its reading time", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: PageCount{}},
+	{Path: "/count/{url}", Summary: "Count a page using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: PageCount{}},
+	{Path: "/outline/{url}/{scheme}", Summary: "Show the structural elements of a page as a tree with word counts", Params: []string{"url", "scheme"}, Query: outlineQueryParams, Response: PageOutline{}},
+	{Path: "/outline/{url}", Summary: "Outline a page using the default scheme", Params: []string{"url"}, Query: outlineQueryParams, Response: PageOutline{}},
+	{Path: "/diff/{url}/{scheme}", Summary: "Compare a live read of a page with its cached copy by article and content hash", Params: []string{"url", "scheme"}, Query: extractQueryParams, Response: PageDiff{}},
+	{Path: "/diff/{url}", Summary: "Diff a page using the default scheme", Params: []string{"url"}, Query: extractQueryParams, Response: PageDiff{}},
+	{Path: "/sitemap/{url}/{scheme}", Summary: "List the page urls of a host's sitemaps, found through robots.txt, within size, time and count limits", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: SiteMap{}},
+	{Path: "/sitemap/{url}", Summary: "List sitemap urls using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: SiteMap{}},
+	{Path: "/checklinks/{url}/{scheme}", Summary: "Probe the links of a page and list those that are broken, a few at a time and spaced per host", Params: []string{"url", "scheme"}, Query: fetchQueryParams, Response: LinkCheckResult{}},
+	{Path: "/checklinks/{url}", Summary: "Check the links of a page using the default scheme", Params: []string{"url"}, Query: fetchQueryParams, Response: LinkCheckResult{}},
+	{Method: "post", Path: "/batch", Summary: "Read the pages of a JSON {\"urls\": [...], \"headers\": {...}} body, forwarding the whitelisted headers and fetching repeated urls once, replayed for a repeated Idempotency-Key header", Query: append([]string{"format"}, fetchQueryParams...), Response: BatchResult{}},
+	{Path: "/cache", Summary: "List cached entries with their remaining TTL, requires the X-API-Key header", Query: []string{"limit"}, Response: CacheListing{}},
+	{Method: "delete", Path: "/cache", Summary: "Purge all cached pages, requires the X-API-Key header", Response: map[string]interface{}{}},
+	{Path: "/selftest", Summary: "Read a built-in fixture page through the fetch and extraction pipeline, answering 503 when a check fails", Response: SelfTestResult{}},
+}
+
+var apiParamDescriptions = map[string]string{
+	"url":       "Host and path of the target page without the scheme, with slashes in the path escaped as %2F",
+	"scheme":    "Protocol of the target page, http or https. Defaults to DEFAULT_SCHEME when omitted",
+	"cacheMode": "Use refresh to skip the cached copy and store the live page, or bypass (also nocache) to read the page live without reading or writing the cache",
+}
+
+var apiQueryDescriptions = map[string]string{
+	"header":          "Repeatable Name:value header forwarded upstream, limited to FORWARD_HEADERS",
+	"cookie":          "Repeatable name=value cookie sent with the upstream fetch",
+	"lang":            "Preferred content language sent upstream as Accept-Language, also selects the stopword list",
+	"titleSelectors":  "Comma separated CSS selectors tried in order for each article title before h1-h3",
+	"timings":         "When true, include per-phase durations in milliseconds, also sent as Server-Timing, with their fetchMs, parseMs, extractMs and totalMs summary to tell upstream latency from extraction time. fetchMs includes the HTML parse and any render, parseMs the reads of the whole page such as its text, language and structured data, extractMs its articles and links",
+	"segment":         "When true, pages without articles are split into sections at h2/h3 headings",
+	"tags":            "Comma separated elements compared as content blocks, defaults to div,article,section,aside",
+	"keyword":         "Word or phrase whose occurrences and density per thousand words are counted",
+	"headers":         "When true, include the upstream response headers except hop-by-hop headers and Set-Cookie",
+	"case":            "Key casing of the response, camel by default or snake for snake_case keys",
+	"minWords":        "Minimum number of words outside links, pages below it get exists false and softError true",
+	"maxPages":        "Maximum number of pages fetched by the crawl, capped by CRAWL_MAX_PAGES",
+	"paginate":        "When true, the content of a single article split across pages is merged by following rel=next links inside it",
+	"partial":         "When true, articles extracted before FETCH_TIMEOUT_SECONDS elapses are returned with truncated true",
+	"maxContentBytes": "Maximum size in bytes of each article's html content, cut at a tag boundary and flagged with contentTruncated",
+	"dedupe":          "Articles repeating the permalink or the text of an earlier article are dropped. This is on by default, where it used to be opt-in, and false returns the raw list",
+	"depth":           "Maximum nesting depth of the outline, 6 by default and at most 20",
+	"minNodeWords":    "Elements with fewer words are left out of the outline, 10 by default",
+	"limit":           "Maximum number of entries listed, cached entries 1000 by default and at most 10000, or articles and links of a page with a Link rel=next header when more remain",
+	"pretty":          "When true or 1, the JSON response is indented",
+	"sanitize":        "When true, article html keeps only allowlisted structural markup, without scripts, event handlers or unsafe urls",
+	"noscript":        "Use include to count and extract <noscript> fallback content, which is removed by default",
+	"mode":            "Use readable to return the single best scoring main content block instead of article elements",
+	"retryEmpty":      "When true, a page that exists but yields no articles is fetched once more after RETRY_EMPTY_DELAY_MS",
+	"maxContentChars": "Maximum length in characters of the text of each article's html content, markup not counting, ended with an ellipsis and flagged with contentTruncated",
+	"render":          "1 fetches the page again through the headless render service configured with RENDER_URL when it has almost no content, js always fetches it through the render service, for pages built by scripts",
+	"fields":          "Comma separated top level fields to return, e.g. title,links, unknown names are rejected with 400",
+	"comments":        "When true, list the author and text of each comment in the page's comment threads",
+	"format":          "ndjson streams one JSON object per line as each page completes, instead of a single response",
+	"maxLinks":        "Maximum number of page links returned, flagged with linksTruncated when more were found",
+	"since":           "Only return articles published from this RFC3339 time, date or period back from now such as 7d, 24h or 2w, a malformed value is rejected with 400",
+	"until":           "Only return articles published up to this RFC3339 time, date, including the whole day, or period back from now, a malformed value is rejected with 400",
+	"undated":         "exclude drops articles without a publication date when since or until is set, by default they are kept",
+	"minTitleWords":   "Drop articles whose title has fewer words, such as Archives or Categories widgets, defaults to MIN_TITLE_WORDS",
+	"minTitleChars":   "Drop articles whose title has fewer characters, defaults to MIN_TITLE_CHARS",
+	"altText":         "When true, list the non-empty alt texts of the page's images, images without alt are always counted",
+	"requireLang":     "Primary language subtag such as en; pages detected in another language return no articles or links and are flagged with langMismatch, pages of uncertain language are kept and flagged with langUncertain",
+	"timing":          "Alias of timings",
+	"offset":          "Number of articles and links of the page skipped, with or without limit. Both lists share the offset, so later windows may hold links only",
+	"groupLinks":      "When true, also return linkGroups, the links of the page keyed by their nearest nav, header, footer, article, aside or main, e.g. nav#primary.menu, or body",
+}
+
+// schemaRef registers the schema for a struct type in schemas and returns a reference to it,
+// other types are described inline
+func schemaRef(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaRef(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	case reflect.Struct:
+		name := t.Name()
+		if _, exists := schemas[name]; !exists {
+			// reserve the name first so self-referencing types terminate
+			schemas[name] = map[string]interface{}{}
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		// untagged embedded structs are flattened by encoding/json, so their fields are listed inline
+		if field.Anonymous && len(name) < 1 && field.Type.Kind() == reflect.Struct {
+			embedded := structSchema(field.Type, schemas)["properties"].(map[string]interface{})
+			for key, value := range embedded {
+				properties[key] = value
+			}
+			continue
+		}
+		if len(name) < 1 {
+			name = field.Name
+		}
+		properties[name] = schemaRef(field.Type, schemas)
+	}
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+func buildApiSpec() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for i := 0; i < len(apiRoutes); i++ {
+		route := apiRoutes[i]
+		params := []interface{}{}
+		for j := 0; j < len(route.Params); j++ {
+			name := route.Params[j]
+			params = append(params, map[string]interface{}{
+				"name":        name,
+				"in":          "path",
+				"required":    true,
+				"description": apiParamDescriptions[name],
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		query := append(append([]string{}, route.Query...), responseQueryParams...)
+		for j := 0; j < len(query); j++ {
+			name := query[j]
+			params = append(params, map[string]interface{}{
+				"name":        name,
+				"in":          "query",
+				"description": apiQueryDescriptions[name],
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+		method := route.Method
+		if len(method) < 1 {
+			method = "get"
+		}
+		operations, exists := paths[route.Path].(map[string]interface{})
+		if !exists {
+			operations = map[string]interface{}{}
+			paths[route.Path] = operations
+		}
+		operations[method] = map[string]interface{}{
+			"summary":    route.Summary,
+			"parameters": params,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": schemaRef(reflect.TypeOf(route.Response), schemas),
+						},
+					},
+				},
+			},
+		}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Text Crawler",
+			"version": "1.0.0",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas},
+	}
+}
+
+func openApiJson(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	json.NewEncoder(w).Encode(buildApiSpec())
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gorilla/mux"
+)
+
+type OutlineNode struct {
+	ClassesIdSet
+	Children []OutlineNode `json:"children"`
+}
+
+type PageOutline struct {
+	Uri     string        `json:"uri"`
+	Exists  bool          `json:"exists"`
+	Outline []OutlineNode `json:"outline"`
+}
+
+// structural elements shown in the outline, other elements are looked through
+var outlineTags = []string{"main", "header", "footer", "nav", "aside", "section", "article", "div"}
+
+const defaultOutlineDepth = 6
+
+const maxOutlineDepth = 20
+
+const defaultOutlineMinWords = 10
+
+// outlineSet describes an element with only its direct parent as the parent path,
+// as the tree already holds the ancestors
+func outlineSet(selection *goquery.Selection, parent *ClassesIdSet) ClassesIdSet {
+	set := ClassesIdSet{
+		Id:        selection.AttrOr("id", ""),
+		Classes:   extractClasses(selection),
+		WordCount: extractNumWords(selection),
+		TagName:   goquery.NodeName(selection),
+	}
+	if parent != nil {
+		parentSet := *parent
+		parentSet.ParentPath = ""
+		set.ParentPath = parentSet.ToPath()
+	}
+	return set
+}
+
+// outlineChildren returns the structural descendants of selection with at least minWords words,
+// down to maxDepth levels of nesting
+func outlineChildren(selection *goquery.Selection, parent *ClassesIdSet, depth int, maxDepth int, minWords int) []OutlineNode {
+	nodes := []OutlineNode{}
+	selection.Children().Each(func(_ int, child *goquery.Selection) {
+		if !stringInList(outlineTags, goquery.NodeName(child)) {
+			nodes = append(nodes, outlineChildren(child, parent, depth, maxDepth, minWords)...)
+			return
+		}
+		set := outlineSet(child, parent)
+		if set.WordCount < minWords {
+			return
+		}
+		node := OutlineNode{ClassesIdSet: set, Children: []OutlineNode{}}
+		if depth < maxDepth {
+			node.Children = outlineChildren(child, &set, depth+1, maxDepth, minWords)
+		}
+		nodes = append(nodes, node)
+	})
+	return nodes
+}
+
+func readLiveOutline(ctx context.Context, uri string, maxDepth int, minWords int, opts FetchOptions) PageOutline {
+	outline := PageOutline{Uri: uri, Outline: []OutlineNode{}}
+	bow, err := fetchPage(ctx, uri, opts)
+	if err != nil {
+		return outline
+	}
+	outline.Exists = true
+	body := bow.Find("body").First()
+	body.Find(strippedMediaTags).Remove()
+	outline.Outline = outlineChildren(body, nil, 1, maxDepth, minWords)
+	return outline
+}
+
+func outlinePage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	maxDepth := queryInt(r, "depth")
+	if maxDepth < 1 {
+		maxDepth = defaultOutlineDepth
+	}
+	if maxDepth > maxOutlineDepth {
+		maxDepth = maxOutlineDepth
+	}
+	minWords := defaultOutlineMinWords
+	if len(r.URL.Query().Get("minNodeWords")) > 0 {
+		minWords = queryInt(r, "minNodeWords")
+	}
+	data := readLiveOutline(r.Context(), url, maxDepth, minWords, fetchOptionsFromRequest(r))
+	writeJson(w, r, data)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/headzoo/surf/browser"
+)
+
+// maximum number of pages, including the first, merged into one article
+var maxContentPages = envInt("MAX_CONTENT_PAGES", 5)
+
+// nextContentPage returns the rel=next link inside the content region, ignoring pagination of listings
+// placed outside the article
+func nextContentPage(bow *browser.Browser) string {
+	region := findArticleElements(bow).First()
+	if region.Length() < 1 {
+		region = bow.Find("main").First()
+	}
+	href := strings.TrimSpace(region.Find("a[rel~='next']").First().AttrOr("href", ""))
+	if len(href) < 1 {
+		return ""
+	}
+	uri, err := bow.ResolveStringUrl(href)
+	if err != nil {
+		return ""
+	}
+	return uri
+}
+
+// mergeContentPages follows the rel=next links of a paginated article, appending the content
+// of each following page to the article, and returns warnings for pages that could not be merged
+func mergeContentPages(ctx context.Context, bow *browser.Browser, article *Article, opts FetchOptions) []string {
+	warnings := []string{}
+	seen := map[string]bool{}
+	if bow.Url() != nil {
+		seen[bow.Url().String()] = true
+	}
+	current := bow
+	for i := 1; i < maxContentPages; i++ {
+		next := nextContentPage(current)
+		if len(next) < 1 || seen[next] {
+			break
+		}
+		seen[next] = true
+		nextBow, err := fetchPage(ctx, next, opts)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("content page %d could not be fetched", i+1))
+			break
+		}
+		region := findArticleElements(nextBow).First()
+		region.Find(articleStrippedTags).Remove()
+		content, err := articleContent(region)
+		if err != nil || len(content) < 1 {
+			warnings = append(warnings, fmt.Sprintf("content page %d had no article", i+1))
+			break
+		}
+		article.Content += "\n" + content
+		current = nextBow
+	}
+	return warnings
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pagedArticleServer serves a story split over /story and /story/2, each page linking back to the first
+func pagedArticleServer(t *testing.T) *httptest.Server {
+	pages := map[string]string{
+		"/story":   `<article><h1><a href="/story">Story</a></h1><p>Part one.</p><a rel="next" href="/story/2">Next</a></article><nav><a rel="next" href="/page/2">Older posts</a></nav>`,
+		"/story/2": `<article><h1><a href="/story">Story</a></h1><p>Part two.</p><a rel="next" href="/story">Back to the start</a></article>`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		html, found := pages[r.URL.Path]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, html)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPaginatedArticlesAreMerged(t *testing.T) {
+	server := pagedArticleServer(t)
+	page := readLiveBlogPage(context.Background(), server.URL+"/story", FetchOptions{Extract: ExtractOptions{MergePages: true}})
+	if len(page.Articles) != 1 {
+		t.Fatalf("expected a single article, got %d", len(page.Articles))
+	}
+	content := page.Articles[0].Content
+	if !strings.Contains(content, "Part one.") || !strings.Contains(content, "Part two.") || strings.Count(content, "Part one.") != 1 {
+		t.Errorf("expected both parts once each, got %s", content)
+	}
+	for i := 0; i < len(page.Warnings); i++ {
+		if strings.HasPrefix(page.Warnings[i], "content page") {
+			t.Errorf("expected the pages to merge cleanly, got %v", page.Warnings)
+		}
+	}
+	unmerged := readLiveBlogPage(context.Background(), server.URL+"/story", FetchOptions{})
+	if strings.Contains(unmerged.Articles[0].Content, "Part two.") {
+		t.Errorf("expected the second page to be read only with paginate")
+	}
+}
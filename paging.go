@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// window keeps limit articles and links from offset, a limit of 0 keeping all the rest, and reports
+// whether either list continues past the window. Both lists share the offset, so once one list
+// runs out the next windows hold only the other. It applies to the response only
+func (p *Page) window(offset, limit int) bool {
+	if offset < 1 && limit < 1 {
+		return false
+	}
+	more := limit > 0 && (len(p.Articles) > offset+limit || len(p.Links) > offset+limit)
+	p.Articles = p.Articles[windowStart(len(p.Articles), offset):windowEnd(len(p.Articles), offset, limit)]
+	p.Links = p.Links[windowStart(len(p.Links), offset):windowEnd(len(p.Links), offset, limit)]
+	return more
+}
+
+func windowStart(length, offset int) int {
+	if offset > length {
+		return length
+	}
+	return offset
+}
+
+func windowEnd(length, offset, limit int) int {
+	if limit < 1 || offset+limit > length {
+		return length
+	}
+	return offset + limit
+}
+
+// setNextLink sends an RFC 5988 Link header pointing to the next window of the same request
+func setNextLink(w http.ResponseWriter, r *http.Request, offset, limit int) {
+	query := r.URL.Query()
+	query.Set("offset", strconv.Itoa(offset+limit))
+	query.Set("limit", strconv.Itoa(limit))
+	w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.EscapedPath(), query.Encode()))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func numberedPage(articles, links int) Page {
+	page := Page{}
+	for i := 0; i < articles; i++ {
+		page.Articles = append(page.Articles, Article{Title: string(rune('a' + i))})
+	}
+	for i := 0; i < links; i++ {
+		page.Links = append(page.Links, LinkItem{Uri: "/" + string(rune('a'+i))})
+	}
+	return page
+}
+
+func TestWindowHonoursOffsetWithoutLimit(t *testing.T) {
+	page := numberedPage(3, 5)
+	if more := page.window(2, 0); more {
+		t.Errorf("expected no next window without a limit")
+	}
+	if len(page.Articles) != 1 || page.Articles[0].Title != "c" || len(page.Links) != 3 || page.Links[0].Uri != "/c" {
+		t.Errorf("expected the lists from the third item, got %+v", page)
+	}
+}
+
+func TestWindowContinuesWhileEitherListRemains(t *testing.T) {
+	page := numberedPage(2, 5)
+	if more := page.window(2, 2); !more {
+		t.Errorf("expected a next window while links remain")
+	}
+	if len(page.Articles) != 0 || len(page.Links) != 2 {
+		t.Errorf("expected no articles and 2 links past the articles, got %d and %d", len(page.Articles), len(page.Links))
+	}
+	last := numberedPage(2, 5)
+	if more := last.window(4, 2); more || len(last.Links) != 1 {
+		t.Errorf("expected the last window to hold 1 link and end, got %d links, more=%v", len(last.Links), more)
+	}
+}
+
+func TestNextLinkKeepsTheQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/extract/example.com?limit=10&offset=10&dedupe=false", nil)
+	w := httptest.NewRecorder()
+	setNextLink(w, r, 10, 10)
+	link := w.Header().Get("Link")
+	if !strings.Contains(link, "offset=20") || !strings.Contains(link, "dedupe=false") || !strings.HasSuffix(link, `rel="next"`) {
+		t.Errorf("expected the next window of the same request, got %s", link)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+// elements publishers use for paywall overlays and subscriber-only blocks
+const paywallSelector = "[class*='paywall'],[id*='paywall'],[class*='subscriber-only'],[class*='subscribers-only'],[class*='premium-content'],[class*='regwall'],[data-paywall]"
+
+// schema.org marks restricted articles with isAccessibleForFree false
+var notFreeRgx = regexp.MustCompile(`"isAccessibleForFree"\s*:\s*"?(false|False)"?`)
+
+// a single article with fewer words than this multiple of the description length is probably a teaser
+const paywallTeaserRatio = 2
+
+// hasPaywallMarkers checks page level signals of restricted content, read before scripts are stripped
+func hasPaywallMarkers(bow *browser.Browser) bool {
+	tier := strings.ToLower(bow.Find("meta[property='article:content_tier']").First().AttrOr("content", ""))
+	if tier == "locked" || tier == "metered" {
+		return true
+	}
+	marked := false
+	bow.Find("script[type='application/ld+json']").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		marked = notFreeRgx.MatchString(s.Text())
+		return !marked
+	})
+	return marked || bow.Find(paywallSelector).Length() > 0
+}
+
+// isPaywalledArticle combines page markers with markers inside the article and, for a page
+// holding one article, a body barely longer than the page description
+func isPaywalledArticle(article *goquery.Selection, pageMarked bool, pageDescription string, numArticles int) bool {
+	if article.Find(paywallSelector).Length() > 0 {
+		return true
+	}
+	if numArticles != 1 {
+		return false
+	}
+	descriptionWords := len(strings.Fields(pageDescription))
+	teaser := descriptionWords > 0 && extractNumWords(article) <= descriptionWords*paywallTeaserRatio
+	return pageMarked || teaser
+}
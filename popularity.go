@@ -0,0 +1,60 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// elements showing the comment and share counts of a post, which vary by theme and sharing plugin
+var commentCountSelectors = envList("COMMENT_COUNT_SELECTORS", []string{"[itemprop='commentCount']", "[data-comment-count]", ".comment-count", ".comments-count"})
+
+var shareCountSelectors = envList("SHARE_COUNT_SELECTORS", []string{"[data-shares]", "[data-share-count]", ".share-count", ".shares-count"})
+
+// attributes holding the count, read before the element text
+var countAttributes = []string{"content", "data-comment-count", "data-shares", "data-share-count"}
+
+// counts are written as 12, 1,234 or abbreviated as 1.2k or 3M
+var displayCountRgx = regexp.MustCompile(`(?i)(\d[\d,]*(?:\.\d+)?)(?:\s*([km])\b)?`)
+
+func parseDisplayCount(text string) (int, bool) {
+	parts := displayCountRgx.FindStringSubmatch(text)
+	if parts == nil {
+		return 0, false
+	}
+	num, err := strconv.ParseFloat(strings.Replace(parts[1], ",", "", -1), 64)
+	if err != nil {
+		return 0, false
+	}
+	switch strings.ToLower(parts[2]) {
+	case "k":
+		num *= 1000
+	case "m":
+		num *= 1000000
+	}
+	return int(num), true
+}
+
+// readDisplayCount returns the first count shown by an element matching selectors within container,
+// nil when there is none, so absent markers are left out of the response
+func readDisplayCount(container *goquery.Selection, selectors []string) *int {
+	for i := 0; i < len(selectors); i++ {
+		marker := container.Find(selectors[i]).First()
+		if marker.Length() < 1 {
+			continue
+		}
+		candidates := []string{}
+		for j := 0; j < len(countAttributes); j++ {
+			candidates = append(candidates, marker.AttrOr(countAttributes[j], ""))
+		}
+		candidates = append(candidates, marker.Text())
+		for j := 0; j < len(candidates); j++ {
+			if count, ok := parseDisplayCount(candidates[j]); ok {
+				return &count
+			}
+		}
+	}
+	return nil
+}
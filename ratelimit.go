@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minimum time between two requests to the same host, so checks and crawls stay polite
+var hostMinInterval = time.Duration(envInt("HOST_MIN_INTERVAL_MS", 200)) * time.Millisecond
+
+// longest a request waits for its turn at a busy host before it is skipped
+var hostMaxWait = time.Duration(envInt("HOST_MAX_WAIT_SECONDS", 10)) * time.Second
+
+// hostLimiter spaces requests to each host by a minimum interval
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{interval: interval, next: map[string]time.Time{}}
+}
+
+var hostRateLimiter = newHostLimiter(hostMinInterval)
+
+// errHostBusy is returned for a polite fetch skipped because its host stayed busy past hostMaxWait
+var errHostBusy = errors.New("skipped as the host stayed busy past the rate limit wait")
+
+// prune drops the slots already past, which leave their hosts free, so the map only
+// holds hosts requested within the last interval
+func (hl *hostLimiter) prune(now time.Time) {
+	for host, slot := range hl.next {
+		if !slot.After(now) {
+			delete(hl.next, host)
+		}
+	}
+}
+
+// wait blocks until host may be requested again, reserving the slot. It returns false without
+// waiting when the slot is further away than maxWait, or when ctx ends first
+func (hl *hostLimiter) wait(ctx context.Context, host string, maxWait time.Duration) bool {
+	host = strings.ToLower(host)
+	hl.mu.Lock()
+	now := time.Now()
+	slot := hl.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	if slot.Sub(now) > maxWait {
+		hl.mu.Unlock()
+		return false
+	}
+	hl.prune(now)
+	hl.next[host] = slot.Add(hl.interval)
+	hl.mu.Unlock()
+	timer := time.NewTimer(slot.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
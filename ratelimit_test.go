@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterSpacesRequestsToAHost(t *testing.T) {
+	limiter := newHostLimiter(50 * time.Millisecond)
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if !limiter.wait(context.Background(), "example.com", time.Second) {
+			t.Fatalf("request %d was refused", i+1)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("expected 3 requests to take at least 100ms, took %s", elapsed)
+	}
+	if !limiter.wait(context.Background(), "other.example.com", 0) {
+		t.Errorf("expected another host to be free at once")
+	}
+}
+
+func TestHostLimiterRefusesWaitsPastTheLimit(t *testing.T) {
+	limiter := newHostLimiter(time.Hour)
+	limiter.wait(context.Background(), "example.com", 0)
+	if limiter.wait(context.Background(), "example.com", time.Second) {
+		t.Errorf("expected a wait of an hour to be refused")
+	}
+}
+
+func TestHostLimiterPrunesPastSlots(t *testing.T) {
+	limiter := newHostLimiter(time.Millisecond)
+	limiter.wait(context.Background(), "a.example.com", time.Second)
+	limiter.wait(context.Background(), "b.example.com", time.Second)
+	time.Sleep(5 * time.Millisecond)
+	limiter.wait(context.Background(), "c.example.com", time.Second)
+	if len(limiter.next) != 1 {
+		t.Errorf("expected only the latest host to be held, got %d hosts", len(limiter.next))
+	}
+}
+
+func TestPoliteFetchIsSkippedWhenTheHostStaysBusy(t *testing.T) {
+	previous, previousWait := hostRateLimiter, hostMaxWait
+	hostRateLimiter, hostMaxWait = newHostLimiter(time.Hour), 0
+	defer func() { hostRateLimiter, hostMaxWait = previous, previousWait }()
+	server := serveHtml(t, "<p>page</p>")
+	opts := FetchOptions{Polite: true}
+	if _, err := fetchPage(context.Background(), server.URL+"/a", opts); err != nil {
+		t.Fatalf("expected the first fetch to go through, got %v", err)
+	}
+	if _, err := fetchPage(context.Background(), server.URL+"/b", opts); err != errHostBusy {
+		t.Errorf("expected the second fetch to be skipped, got %v", err)
+	}
+}
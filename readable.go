@@ -0,0 +1,141 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+	"golang.org/x/net/html"
+)
+
+// class and id hints that a block holds the main content or page furniture
+var positiveHintRgx = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text|blog`)
+
+var negativeHintRgx = regexp.MustCompile(`(?i)comment|footer|header|sidebar|nav|menu|share|social|promo|related|widget|banner|sponsor|advert|cookie|popup`)
+
+// paragraphs shorter than this many characters do not add to the score of their container
+const minScoredTextLength = 25
+
+// tagWeight favours elements that usually wrap prose over lists, forms and headings
+func tagWeight(tagName string) float64 {
+	switch tagName {
+	case "article", "main":
+		return 10
+	case "div", "section":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form", "aside":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th", "nav", "footer", "header":
+		return -5
+	}
+	return 0
+}
+
+func classWeight(selection *goquery.Selection) float64 {
+	hints := selection.AttrOr("class", "") + " " + selection.AttrOr("id", "")
+	weight := 0.0
+	if negativeHintRgx.MatchString(hints) {
+		weight -= 25
+	}
+	if positiveHintRgx.MatchString(hints) {
+		weight += 25
+	}
+	return weight
+}
+
+// linkDensity is the share of the words of selection that sit inside links
+func linkDensity(selection *goquery.Selection) float64 {
+	words := extractNumWords(selection)
+	if words < 1 {
+		return 0
+	}
+	linkWords := 0
+	selection.Find("a").Each(func(_ int, s *goquery.Selection) {
+		linkWords += len(strings.Fields(s.Text()))
+	})
+	return float64(linkWords) / float64(words)
+}
+
+// scoreParagraph rates a block of text by its commas and length, as in Arc90's readability
+func scoreParagraph(text string) float64 {
+	score := 1 + float64(strings.Count(text, ","))
+	lengthBonus := float64(len(text)) / 100
+	if lengthBonus > 3 {
+		lengthBonus = 3
+	}
+	return score + lengthBonus
+}
+
+// findReadableContent scores the parent and grandparent of each paragraph and returns the
+// best scoring container after penalising link heavy blocks
+func findReadableContent(bow *browser.Browser) *goquery.Selection {
+	body := bow.Find("body").First()
+	scores := map[*html.Node]float64{}
+	candidates := []*goquery.Selection{}
+	addScore := func(selection *goquery.Selection, score float64) {
+		if selection.Length() < 1 {
+			return
+		}
+		node := selection.Get(0)
+		if _, exists := scores[node]; !exists {
+			scores[node] = tagWeight(goquery.NodeName(selection)) + classWeight(selection)
+			candidates = append(candidates, selection)
+		}
+		scores[node] += score
+	}
+	body.Find("p,pre,td,blockquote").Each(func(_ int, s *goquery.Selection) {
+		text := removeSpaces(s.Text())
+		if len(text) < minScoredTextLength {
+			return
+		}
+		score := scoreParagraph(text)
+		parent := s.Parent()
+		addScore(parent, score)
+		addScore(parent.Parent(), score/2)
+	})
+	var best *goquery.Selection
+	bestScore := 0.0
+	for i := 0; i < len(candidates); i++ {
+		score := scores[candidates[i].Get(0)] * (1 - linkDensity(candidates[i]))
+		if best == nil || score > bestScore {
+			best = candidates[i]
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// readReadableArticle extracts the single best main content block of the page as an article
+func readReadableArticle(bow *browser.Browser) (Article, bool) {
+	content := findReadableContent(bow)
+	if content == nil {
+		return Article{}, false
+	}
+	title := removeSpaces(strings.TrimSpace(content.Find("h1").First().Text()))
+	if len(title) < 1 {
+		title = removeSpaces(strings.TrimSpace(bow.Find("h1").First().Text()))
+	}
+	if len(title) < 1 {
+		title = bow.Title()
+	}
+	uri := ""
+	if bow.Url() != nil {
+		uri = bow.Url().String()
+	}
+	pageDescription := bow.Find("meta[property='og:description']").First().AttrOr("content", "")
+	excerpt := extractExcerpt(content, pageDescription, 1)
+	videos := readEmbeddedVideos(bow, content)
+	content.Find(articleStrippedTags).Remove()
+	contentHtml, err := articleContent(content)
+	if err != nil {
+		return Article{}, false
+	}
+	article := makeArticle(title, uri, contentHtml, articleLinks(content))
+	article.Excerpt = excerpt
+	article.Videos = videos
+	article.Published = articlePublished(content, bow, 1)
+	return article, true
+}
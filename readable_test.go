@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// a news layout with the story in a bare div between menus, a sidebar and a comment thread
+const newsLayout = `<html><head><title>Site | Harbour reopens</title></head><body>
+<header><nav><a href="/">Home</a> <a href="/news">News</a> <a href="/sport">Sport</a></nav></header>
+<div class="wrap">
+<div class="column-left">
+<h1>Harbour reopens after repairs</h1>
+<p>The harbour reopened on Monday, three months after storms damaged the outer wall, the council said.</p>
+<p>Fishing boats, which had moored at the next town along the coast, began returning in the morning.</p>
+<img src="/harbour.jpg" alt="The harbour">
+<p>Repairs cost more than expected, as contractors found older damage beneath the surface.</p>
+</div>
+<aside class="sidebar"><h3>Most read</h3><ul><li><a href="/a">A story about something else entirely, with many words</a></li><li><a href="/b">Another popular story from the week</a></li></ul></aside>
+</div>
+<div id="comments"><p>Great news, finally, we have been waiting for this for months now.</p></div>
+<footer><p>Copyright, all rights reserved, no part may be reproduced.</p></footer>
+</body></html>`
+
+const newsContent = `<h1>Harbour reopens after repairs</h1>
+<p>The harbour reopened on Monday, three months after storms damaged the outer wall, the council said.</p>
+<p>Fishing boats, which had moored at the next town along the coast, began returning in the morning.</p>
+
+<p>Repairs cost more than expected, as contractors found older damage beneath the surface.</p>`
+
+// a blog layout with the post in a classed content block nested in a main element
+const blogLayout = `<html><head><title>Notes</title><meta property="og:description" content="Why small functions help."></head><body>
+<main><div class="post-body entry-content">
+<p>Small functions are easier to name, which makes code easier to read, test and change.</p>
+<pre>func add(a, b int) int {
+    return a + b
+}</pre>
+<p>They also keep state local, so fewer things can go wrong at once, in my experience.</p>
+</div>
+<div class="share-links"><a href="/share/x">Share on X</a> <a href="/share/fb">Share on Facebook</a></div>
+</main></body></html>`
+
+const blogContent = `<p>Small functions are easier to name, which makes code easier to read, test and change.</p>
+<pre>func add(a, b int) int {
+    return a + b
+}</pre>
+<p>They also keep state local, so fewer things can go wrong at once, in my experience.</p>`
+
+func TestReadableModeFixtures(t *testing.T) {
+	cases := []struct {
+		name, html, title, content, excerpt string
+	}{
+		{"news", newsLayout, "Harbour reopens after repairs", newsContent, "The harbour reopened on Monday,"},
+		{"blog", blogLayout, "Notes", blogContent, "Why small functions help."},
+	}
+	for _, c := range cases {
+		server := serveHtml(t, c.html)
+		page := readLiveBlogPage(context.Background(), server.URL+"/"+c.name, FetchOptions{Extract: ExtractOptions{Readable: true}})
+		if len(page.Articles) != 1 {
+			t.Fatalf("%s: expected a single article, got %d", c.name, len(page.Articles))
+		}
+		article := page.Articles[0]
+		if article.Title != c.title || article.Uri != server.URL+"/"+c.name {
+			t.Errorf("%s: expected %q at the page url, got %q at %s", c.name, c.title, article.Title, article.Uri)
+		}
+		if strings.TrimSpace(article.Content) != c.content {
+			t.Errorf("%s: expected content\n%s\ngot\n%s", c.name, c.content, article.Content)
+		}
+		if !strings.HasPrefix(article.Excerpt, c.excerpt) {
+			t.Errorf("%s: expected the excerpt to start with %q, got %q", c.name, c.excerpt, article.Excerpt)
+		}
+	}
+}
+
+func TestReadableModeWithoutProse(t *testing.T) {
+	server := serveHtml(t, `<html><body><nav><a href="/a">A</a></nav><p>Short.</p></body></html>`)
+	page := readLiveBlogPage(context.Background(), server.URL+"/", FetchOptions{Extract: ExtractOptions{Readable: true}})
+	if len(page.Articles) != 0 || !stringInList(page.Warnings, "no readable content found") {
+		t.Errorf("expected no article and a warning, got %d articles and %v", len(page.Articles), page.Warnings)
+	}
+}
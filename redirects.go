@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+var errTooManyRedirects = errors.New("too many redirects")
+
+var errRedirectLoop = errors.New("redirect loop detected")
+
+// checkRedirect rejects a redirect to target once the chain of previous hops is
+// longer than MAX_REDIRECTS or target has already been visited
+func checkRedirect(target string, via []string) error {
+	if len(via) > maxRedirects {
+		return errTooManyRedirects
+	}
+	for i := 0; i < len(via); i++ {
+		if via[i] == target {
+			return errRedirectLoop
+		}
+	}
+	return nil
+}
+
+// guardRedirect is the redirect policy of plain clients, enforcing checkRedirect on the chain they followed
+func guardRedirect(req *http.Request, via []*http.Request) error {
+	visited := []string{}
+	for i := 0; i < len(via); i++ {
+		visited = append(visited, via[i].URL.String())
+	}
+	return checkRedirect(req.URL.String(), visited)
+}
+
+// redirectGuard enforces checkRedirect for browsers whose client redirect policy cannot be replaced,
+// reconstructing the chain from the redirect responses attached to each request
+type redirectGuard struct {
+	base http.RoundTripper
+}
+
+func (rg *redirectGuard) RoundTrip(req *http.Request) (*http.Response, error) {
+	via := []string{}
+	for prev := req.Response; prev != nil && prev.Request != nil; prev = prev.Request.Response {
+		via = append(via, prev.Request.URL.String())
+	}
+	if err := checkRedirect(req.URL.String(), via); err != nil {
+		return nil, err
+	}
+	return rg.base.RoundTrip(req)
+}
+
+// fetchErrorMessage describes a failed fetch for API consumers
+func fetchErrorMessage(err error) string {
+	if errors.Is(err, errTooManyRedirects) {
+		return errTooManyRedirects.Error()
+	}
+	if errors.Is(err, errRedirectLoop) {
+		return errRedirectLoop.Error()
+	}
+	if ctErr, ok := isContentTypeError(err); ok {
+		return ctErr.Error()
+	}
+	return err.Error()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/headzoo/surf/browser"
+)
+
+// renderEndpoint is a render service that loads ?url= in a headless browser and answers with the
+// rendered html, e.g. RENDER_URL=http://localhost:3000/render, unset disables ?render=1 and ?render=js
+var renderEndpoint = envString("RENDER_URL", "")
+
+// pages with fewer words outside links than this are rendered again when ?render=1 is set
+var renderMinWords = envInt("RENDER_MIN_WORDS", 50)
+
+func renderEnabled(opts ExtractOptions) bool {
+	return opts.Render && len(renderEndpoint) > 0
+}
+
+// renderTransport sends each request to the render service instead of the target, passing
+// the response back as the target's own so links still resolve against the page uri.
+// Forwarded headers and cookies are meant for the target and are not sent to the service.
+type renderTransport struct {
+	endpoint string
+	base     http.RoundTripper
+}
+
+func (rt *renderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(rt.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	query := target.Query()
+	query.Set("url", req.URL.String())
+	target.RawQuery = query.Encode()
+	renderReq, err := http.NewRequestWithContext(req.Context(), http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	renderReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+	resp, err := rt.base.RoundTrip(renderReq)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	return resp, nil
+}
+
+// renderPage opens uri through the render service, so pages built by scripts can be extracted.
+// An error status of the service, or of the page it rendered, is returned as an error
+// so its error page is never extracted in place of the page
+func renderPage(ctx context.Context, uri string, opts FetchOptions) (*browser.Browser, error) {
+	bow := newBrowser(ctx, uri, opts)
+	bow.SetTransport(&contextTransport{ctx: ctx, base: &renderTransport{endpoint: renderEndpoint, base: &contentTypeGuard{base: sharedTransport}}})
+	start := time.Now()
+	err := bow.Open(uri)
+	if err == nil && bow.StatusCode() >= 400 {
+		err = fmt.Errorf("render service answered %d", bow.StatusCode())
+	}
+	if err != nil {
+		opts.logger().Printf("render failed uri=%s error=%q", uri, err.Error())
+	} else {
+		opts.logger().Printf("render uri=%s status=%d duration=%s", uri, bow.StatusCode(), time.Since(start))
+	}
+	return bow, err
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// a page whose posts are only added by a script, as the render service would return it once run
+const scriptShell = `<html><body><div id="app"></div><script src="/app.js"></script></body></html>`
+
+const renderedPosts = `<html><body><article><h2><a href="/post">Rendered post</a></h2><p>Text added by the script.</p></article></body></html>`
+
+// withRenderService points RENDER_URL at a mock render service answering with status and html
+func withRenderService(t *testing.T, status int, html string) *[]string {
+	rendered := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rendered = append(rendered, r.URL.Query().Get("url"))
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		fmt.Fprint(w, html)
+	}))
+	previous := renderEndpoint
+	renderEndpoint = server.URL + "/render"
+	t.Cleanup(func() {
+		renderEndpoint = previous
+		server.Close()
+	})
+	return &rendered
+}
+
+func TestThinPagesAreRendered(t *testing.T) {
+	rendered := withRenderService(t, http.StatusOK, renderedPosts)
+	target := serveHtml(t, scriptShell)
+	page := readLiveBlogPage(context.Background(), target.URL+"/", FetchOptions{Extract: ExtractOptions{Render: true}})
+	if !page.Rendered || len(page.Articles) != 1 || page.Articles[0].Title != "Rendered post" {
+		t.Fatalf("expected the rendered post, got rendered=%v articles=%+v", page.Rendered, page.Articles)
+	}
+	if len(*rendered) != 1 || (*rendered)[0] != target.URL+"/" {
+		t.Errorf("expected the page url to be passed to the render service, got %v", *rendered)
+	}
+	if page.Uri != target.URL+"/" || !strings.HasPrefix(page.Articles[0].Uri, "/post") {
+		t.Errorf("expected the page to keep its own url, got %q", page.Uri)
+	}
+}
+
+func TestRenderIsSkippedWithoutARenderService(t *testing.T) {
+	previous := renderEndpoint
+	renderEndpoint = ""
+	defer func() { renderEndpoint = previous }()
+	target := serveHtml(t, scriptShell)
+	page := readLiveBlogPage(context.Background(), target.URL+"/", FetchOptions{Extract: ExtractOptions{Render: true}})
+	if page.Rendered || !page.Exists {
+		t.Errorf("expected the fetched page, got rendered=%v exists=%v", page.Rendered, page.Exists)
+	}
+}
+
+func TestRenderServiceErrorsKeepTheFetchedPage(t *testing.T) {
+	withRenderService(t, http.StatusBadGateway, `<html><body><article><h2><a href="/">Bad gateway</a></h2></article></body></html>`)
+	target := serveHtml(t, scriptShell)
+	page := readLiveBlogPage(context.Background(), target.URL+"/", FetchOptions{Extract: ExtractOptions{Render: true}})
+	if page.Rendered || len(page.Articles) != 0 || len(page.Error) > 0 {
+		t.Errorf("expected the fetched page without the error page, got rendered=%v articles=%+v", page.Rendered, page.Articles)
+	}
+}
+
+func TestForcedRenderSkipsTheDirectFetch(t *testing.T) {
+	rendered := withRenderService(t, http.StatusOK, renderedPosts)
+	target := newRecordingServer(t)
+	opts := FetchOptions{Extract: ExtractOptions{Render: true, RenderAlways: true}}
+	page := readLiveBlogPage(context.Background(), target.URL+"/", opts)
+	if !page.Rendered || len(page.Articles) != 1 || page.Articles[0].Title != "Rendered post" {
+		t.Fatalf("expected the rendered post, got rendered=%v articles=%+v", page.Rendered, page.Articles)
+	}
+	if target.hits != 0 || len(*rendered) != 1 {
+		t.Errorf("expected only the render service to be asked, got %d direct fetches", target.hits)
+	}
+}
+
+func TestForcedRenderReadsPagesTheDirectFetchCannot(t *testing.T) {
+	withRenderService(t, http.StatusOK, renderedPosts)
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	opts := FetchOptions{Extract: ExtractOptions{Render: true, RenderAlways: true}}
+	page := readLiveBlogPage(context.Background(), closed.URL+"/", opts)
+	if !page.Rendered || len(page.Error) > 0 || len(page.Articles) != 1 {
+		t.Errorf("expected the rendered page, got rendered=%v error=%q", page.Rendered, page.Error)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// structural and text elements kept by sanitizeHtml, other elements are replaced by their children
+var sanitizeAllowedTags = []string{
+	"a", "abbr", "b", "blockquote", "br", "caption", "cite", "code", "dd", "del", "dfn", "div", "dl", "dt",
+	"em", "figcaption", "figure", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img", "ins", "kbd", "li",
+	"mark", "ol", "p", "pre", "q", "s", "section", "article", "small", "span", "strong", "sub", "sup",
+	"table", "tbody", "td", "tfoot", "th", "thead", "time", "tr", "u", "ul",
+}
+
+// elements removed along with their content
+var sanitizeDroppedTags = []string{
+	"script", "style", "iframe", "frame", "frameset", "object", "embed", "applet", "form", "input", "button",
+	"select", "textarea", "link", "meta", "base", "svg", "math", "template", "noscript",
+}
+
+// attributes kept on any allowed element, event handlers and styles are always dropped
+var sanitizeAllowedAttrs = []string{"alt", "cite", "colspan", "datetime", "dir", "height", "href", "lang", "rowspan", "src", "start", "title", "width"}
+
+var sanitizeUrlAttrs = []string{"href", "src", "cite"}
+
+// isSafeUrl accepts relative urls and the http, https and mailto schemes, rejecting javascript: and data:
+func isSafeUrl(value string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return false
+	}
+	scheme := strings.ToLower(parsed.Scheme)
+	return scheme == "" || scheme == "http" || scheme == "https" || scheme == "mailto"
+}
+
+func sanitizeAttrs(attrs []html.Attribute) []html.Attribute {
+	kept := []html.Attribute{}
+	for i := 0; i < len(attrs); i++ {
+		key := strings.ToLower(attrs[i].Key)
+		if len(attrs[i].Namespace) > 0 || !stringInList(sanitizeAllowedAttrs, key) {
+			continue
+		}
+		if stringInList(sanitizeUrlAttrs, key) && !isSafeUrl(attrs[i].Val) {
+			continue
+		}
+		kept = append(kept, html.Attribute{Key: key, Val: attrs[i].Val})
+	}
+	return kept
+}
+
+// sanitizeNodes returns the safe form of the children of parent, detached from the original tree
+func sanitizeNodes(parent *html.Node) []*html.Node {
+	nodes := []*html.Node{}
+	for child := parent.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			nodes = append(nodes, &html.Node{Type: html.TextNode, Data: child.Data})
+		case html.ElementNode:
+			tag := strings.ToLower(child.Data)
+			if stringInList(sanitizeDroppedTags, tag) {
+				continue
+			}
+			children := sanitizeNodes(child)
+			if !stringInList(sanitizeAllowedTags, tag) {
+				nodes = append(nodes, children...)
+				continue
+			}
+			node := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag)), Attr: sanitizeAttrs(child.Attr)}
+			for i := 0; i < len(children); i++ {
+				node.AppendChild(children[i])
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// sanitizeHtml keeps the structural markup of an html fragment, removing scripts,
+// embedded content, event handlers, styles and unsafe urls
+func sanitizeHtml(content string) string {
+	container := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	fragment, err := html.ParseFragment(strings.NewReader(content), container)
+	if err != nil {
+		return ""
+	}
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	for i := 0; i < len(fragment); i++ {
+		root.AppendChild(fragment[i])
+	}
+	var buf bytes.Buffer
+	nodes := sanitizeNodes(root)
+	for i := 0; i < len(nodes); i++ {
+		html.Render(&buf, nodes[i])
+	}
+	return buf.String()
+}
+
+func sanitizeArticles(articles []Article) {
+	for i := 0; i < len(articles); i++ {
+		articles[i].Content = sanitizeHtml(articles[i].Content)
+	}
+}
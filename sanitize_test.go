@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeHtmlRemovesScriptVectors(t *testing.T) {
+	vectors := []string{
+		`<a href="javascript:alert(1)">x</a>`,
+		`<a href="JaVaScRiPt:alert(1)">x</a>`,
+		`<a href=" javascript:alert(1)">x</a>`,
+		`<a href="&#106;avascript:alert(1)">x</a>`,
+		`<a href="&#x6A;avascript&colon;alert(1)">x</a>`,
+		`<a href="java&#x09;script:alert(1)">x</a>`,
+		`<a href="java&#10;script:alert(1)">x</a>`,
+		`<img src="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==">`,
+		`<img src="x" onerror="alert(1)">`,
+		`<p onclick="alert(1)" ONMOUSEOVER="alert(1)">x</p>`,
+		`<svg onload="alert(1)"><script>alert(1)</script></svg>`,
+		`<svg><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`,
+		`<math><mtext><a href="javascript:alert(1)">x</a></mtext></math>`,
+		`<p style="background:url(javascript:alert(1))">x</p>`,
+		`<div style="width: expression(alert(1))">x</div>`,
+		`<script>alert(1)</script>`,
+		`<iframe src="javascript:alert(1)"></iframe>`,
+		`<noscript><p title="</noscript><img src=x onerror=alert(1)>"></noscript>`,
+		`<xmp><script>alert(1)</script></xmp>`,
+		`<span>&lt;script&gt;alert(1)&lt;/script&gt;</span>`,
+	}
+	for i := 0; i < len(vectors); i++ {
+		clean := strings.ToLower(sanitizeHtml(vectors[i]))
+		for _, banned := range []string{"javascript", "<script", "onerror", "onclick", "onmouseover", "onload", "style=", "data:", "<svg", "<math", "<iframe"} {
+			if strings.Contains(clean, banned) {
+				t.Errorf("expected %q to be removed from %s, got %s", banned, vectors[i], clean)
+			}
+		}
+	}
+}
+
+func TestSanitizeHtmlKeepsStructureAndSafeUrls(t *testing.T) {
+	clean := sanitizeHtml(`<h2 class="title">Title</h2><p>Read <a href="/post?a=1&amp;b=2" target="_blank">more</a> or <a href="mailto:me@example.com">mail</a>.</p><img src="https://example.com/a.png" alt="A">`)
+	expected := `<h2>Title</h2><p>Read <a href="/post?a=1&amp;b=2">more</a> or <a href="mailto:me@example.com">mail</a>.</p><img src="https://example.com/a.png" alt="A"/>`
+	if clean != expected {
+		t.Errorf("expected %s, got %s", expected, clean)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+// hasExtractedArticles reports whether any of the articles has content
+func hasExtractedArticles(articles []Article) bool {
+	for i := 0; i < len(articles); i++ {
+		if len(articles[i].Content) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// findSectionContainer picks the element holding the most h2/h3 headings as direct children,
+// searching within <main> when the page has one
+func findSectionContainer(bow *browser.Browser) *goquery.Selection {
+	root := bow.Find("main, [role='main']").First()
+	if root.Length() < 1 {
+		root = bow.Find("body")
+	}
+	var best *goquery.Selection
+	bestCount := 0
+	root.Find("h2,h3").Each(func(_ int, s *goquery.Selection) {
+		parent := s.Parent()
+		count := parent.ChildrenFiltered("h2,h3").Length()
+		if count > bestCount {
+			best = parent
+			bestCount = count
+		}
+	})
+	return best
+}
+
+func headingBoundary(tagName string) string {
+	if tagName == "h2" {
+		return "h1,h2"
+	}
+	return "h1,h2,h3"
+}
+
+// readHeadingSections builds one article per heading, with everything up to the next
+// heading of the same or a higher level as its content
+func readHeadingSections(bow *browser.Browser) []Article {
+	articles := []Article{}
+	container := findSectionContainer(bow)
+	if container == nil {
+		return articles
+	}
+	container.Find("img,svg,embed,iframe,object,style,script").Remove()
+	container.ChildrenFiltered("h2,h3").Each(func(_ int, heading *goquery.Selection) {
+		title := removeSpaces(heading.Text())
+		uri := ""
+		if id, exists := heading.Attr("id"); exists {
+			uri = "#" + id
+		}
+		parts := []string{}
+		if headingHtml, err := goquery.OuterHtml(heading); err == nil {
+			parts = append(parts, headingHtml)
+		}
+		body := heading.NextUntil(headingBoundary(goquery.NodeName(heading)))
+		body.Each(func(_ int, s *goquery.Selection) {
+			if html, err := goquery.OuterHtml(s); err == nil {
+				parts = append(parts, html)
+			}
+		})
+		links := []LinkItem{}
+		body.Find("a").Each(func(_ int, s *goquery.Selection) {
+			val, exists := s.Attr("href")
+			if exists && !uriIsInLinkItems(links, val) {
+				links = append(links, makeLinkItem(val, s.Text()))
+			}
+		})
+		content := strings.Trim(strings.Join(parts, "\n"), "\n\t ")
+		articles = append(articles, makeArticle(title, uri, content, links))
+	})
+	return articles
+}
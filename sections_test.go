@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const multiSectionPage = `<html><body><header><h1>Guide</h1></header>
+<main><h1>Setting up</h1><p>Intro text.</p>
+<h2 id="install">Install</h2><p>Download the <a href="/download">package</a>.</p>
+<h3>On Linux</h3><p>Use the package manager.</p>
+<h2 id="configure">Configure</h2><p>Edit the config file.</p>
+<h2>Run</h2><p>Start the <a href="/service">service</a>.</p>
+</main></body></html>`
+
+func TestSegmentHeadingsSplitsASinglePage(t *testing.T) {
+	server := serveHtml(t, multiSectionPage)
+	page := readLiveBlogPage(context.Background(), server.URL+"/guide", FetchOptions{Extract: ExtractOptions{SegmentHeadings: true}})
+	titles := []string{}
+	for i := 0; i < len(page.Articles); i++ {
+		titles = append(titles, page.Articles[i].Title)
+	}
+	if strings.Join(titles, "|") != "Install|On Linux|Configure|Run" {
+		t.Fatalf("expected a section per heading, got %v", titles)
+	}
+	install := page.Articles[0]
+	if install.Uri != "#install" || !strings.Contains(install.Content, "package manager") || strings.Contains(install.Content, "config file") {
+		t.Errorf("expected the install section to run up to the next h2, got %+v", install)
+	}
+	if len(install.Links) != 1 || install.Links[0].Uri != "/download" {
+		t.Errorf("expected the install section links, got %+v", install.Links)
+	}
+	if page.Articles[3].Uri != "" || strings.Contains(page.Articles[3].Content, "Intro") {
+		t.Errorf("expected the last section without an id or the intro, got %+v", page.Articles[3])
+	}
+}
+
+func TestSegmentHeadingsIsOptIn(t *testing.T) {
+	server := serveHtml(t, multiSectionPage)
+	page := readLiveBlogPage(context.Background(), server.URL+"/guide", FetchOptions{})
+	if hasExtractedArticles(page.Articles) {
+		t.Errorf("expected no sections unless asked, got %d articles", len(page.Articles))
+	}
+}
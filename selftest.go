@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// selfTestFixture is a small blog page whose extraction result is known in advance
+const selfTestFixture = `<!DOCTYPE html>
+<html lang="en">
+<head><title>Self test fixture</title></head>
+<body>
+<nav><a href="/about">About</a> <a href="/contact">Contact</a></nav>
+<article>
+<h2><a href="/posts/first">First post</a></h2>
+<p>The first post of the self test fixture has enough words in its body to be read as a real article by the extractor.</p>
+</article>
+<article>
+<h2><a href="/posts/second">Second post</a></h2>
+<p>The second post of the self test fixture also has enough words in its body to be read as a real article by the extractor.</p>
+</article>
+</body>
+</html>`
+
+const (
+	selfTestTitle    = "Self test fixture"
+	selfTestArticles = 2
+	selfTestLinks    = 4
+)
+
+type SelfTestCheck struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Passed   bool   `json:"passed"`
+}
+
+type SelfTestResult struct {
+	Passed bool            `json:"passed"`
+	Checks []SelfTestCheck `json:"checks"`
+	Error  string          `json:"error,omitempty"`
+}
+
+func (result *SelfTestResult) check(name string, expected string, actual string) {
+	passed := expected == actual
+	result.Checks = append(result.Checks, SelfTestCheck{Name: name, Expected: expected, Actual: actual, Passed: passed})
+	result.Passed = result.Passed && passed
+}
+
+// runSelfTest serves the fixture on a loopback port for the duration of the test and reads it
+// through the live fetch and extraction pipeline, bypassing the cache
+func runSelfTest(ctx context.Context, opts FetchOptions) SelfTestResult {
+	result := SelfTestResult{Passed: true, Checks: []SelfTestCheck{}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		result.Passed = false
+		result.Error = err.Error()
+		return result
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write([]byte(selfTestFixture))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+	page := readLiveBlogPage(ctx, "http://"+listener.Addr().String()+"/selftest", opts)
+	if len(page.Error) > 0 {
+		result.Error = page.Error
+	}
+	result.check("exists", "true", strconv.FormatBool(page.Exists))
+	result.check("title", selfTestTitle, page.Title)
+	result.check("articles", strconv.Itoa(selfTestArticles), strconv.Itoa(len(page.Articles)))
+	result.check("links", strconv.Itoa(selfTestLinks), strconv.Itoa(len(page.Links)))
+	return result
+}
+
+// selfTestJson answers 503 when any check fails, so it can gate a deployment
+func selfTestJson(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	opts := fetchOptionsFromRequest(r)
+	result := runSelfTest(r.Context(), opts)
+	opts.logger().Printf("selftest passed=%t duration=%s", result.Passed, time.Since(start))
+	if !result.Passed {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJson(w, r, result)
+}
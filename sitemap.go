@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"gopkg.in/headzoo/surf.v1"
+)
+
+type SiteMap struct {
+	Uri      string   `json:"uri"`
+	Sitemaps []string `json:"sitemaps"`
+	Urls     []string `json:"urls"`
+	Warnings []string `json:"warnings"`
+}
+
+// robots.txt and sitemaps have their own limits, apart from page fetches, so a slow or huge file
+// ends with a partial result instead of holding the request
+var robotsTimeout = time.Duration(envInt("ROBOTS_TIMEOUT_SECONDS", 5)) * time.Second
+
+var robotsMaxBytes = envInt("ROBOTS_MAX_BYTES", 512*1024)
+
+var sitemapTimeout = time.Duration(envInt("SITEMAP_TIMEOUT_SECONDS", 20)) * time.Second
+
+// the sitemap protocol caps a file at 50MB uncompressed, most sites stay far below
+var sitemapMaxBytes = envInt("SITEMAP_MAX_BYTES", 10*1024*1024)
+
+// most sitemaps read from a sitemap index, and page urls returned
+var sitemapMaxChildren = envInt("SITEMAP_MAX_CHILDREN", 20)
+
+var sitemapMaxUrls = envInt("SITEMAP_MAX_URLS", 50000)
+
+// fetchLimited reads at most maxBytes of uri within timeout, reporting whether the body was cut.
+// Gzipped sitemaps are decompressed and the limit applies to the decompressed size
+func fetchLimited(ctx context.Context, uri string, timeout time.Duration, maxBytes int, opts FetchOptions) ([]byte, bool, error) {
+	ctx, cancel := withTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	applyHeaders(opts.requestHeaders(), req.Header.Set)
+	req.Header.Set("User-Agent", surf.DefaultUserAgent)
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var body io.Reader = resp.Body
+	if strings.HasSuffix(strings.ToLower(resp.Request.URL.Path), ".gz") || strings.Contains(resp.Header.Get("Content-Type"), "gzip") {
+		unzipped, err := gzip.NewReader(bufio.NewReader(resp.Body))
+		if err != nil {
+			return nil, false, err
+		}
+		defer unzipped.Close()
+		body = unzipped
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(body, int64(maxBytes)+1))
+	if len(data) > maxBytes {
+		return data[:maxBytes], true, nil
+	}
+	// a deadline hit mid body still leaves the part read so far
+	if err != nil && len(data) > 0 {
+		return data, true, nil
+	}
+	return data, false, err
+}
+
+// robotsSitemaps lists the Sitemap: lines of a robots.txt
+func robotsSitemaps(robots []byte) []string {
+	sitemaps := []string{}
+	lines := strings.Split(string(robots), "\n")
+	for i := 0; i < len(lines); i++ {
+		parts := strings.SplitN(strings.TrimSpace(lines[i]), ":", 2)
+		if len(parts) == 2 && strings.EqualFold(strings.TrimSpace(parts[0]), "sitemap") {
+			if uri := strings.TrimSpace(parts[1]); len(uri) > 0 && !stringInList(sitemaps, uri) {
+				sitemaps = append(sitemaps, uri)
+			}
+		}
+	}
+	return sitemaps
+}
+
+// parseSitemap streams the <loc> entries of a urlset or sitemapindex, so a cut or malformed
+// file still yields the entries before the break, which is reported as incomplete
+func parseSitemap(data []byte) (urls []string, children []string, complete bool) {
+	urls, children = []string{}, []string{}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	parent := ""
+	inLoc := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return urls, children, true
+		}
+		if err != nil {
+			return urls, children, false
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "url", "sitemap":
+				parent = t.Name.Local
+			case "loc":
+				inLoc = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == "loc" {
+				inLoc = false
+			}
+		case xml.CharData:
+			if !inLoc {
+				continue
+			}
+			loc := strings.TrimSpace(string(t))
+			if len(loc) < 1 {
+				continue
+			}
+			if parent == "sitemap" {
+				children = append(children, loc)
+			} else {
+				urls = append(urls, loc)
+			}
+		}
+	}
+}
+
+// readSiteMap collects page urls from the sitemaps listed in robots.txt, or /sitemap.xml
+// when it lists none, following sitemap indexes up to SITEMAP_MAX_CHILDREN files
+func readSiteMap(ctx context.Context, root string, opts FetchOptions) SiteMap {
+	siteMap := SiteMap{Uri: root, Sitemaps: []string{}, Urls: []string{}, Warnings: []string{}}
+	queue := []string{}
+	robots, truncated, err := fetchLimited(ctx, root+"/robots.txt", robotsTimeout, robotsMaxBytes, opts)
+	if err == nil {
+		if truncated {
+			siteMap.Warnings = append(siteMap.Warnings, "robots.txt exceeded the size limit and was read in part")
+		}
+		queue = robotsSitemaps(robots)
+	}
+	if len(queue) < 1 {
+		queue = []string{root + "/sitemap.xml"}
+	}
+	seen := map[string]bool{}
+	for len(queue) > 0 && ctx.Err() == nil {
+		uri := queue[0]
+		queue = queue[1:]
+		if seen[uri] {
+			continue
+		}
+		if len(siteMap.Sitemaps) >= sitemapMaxChildren {
+			siteMap.Warnings = append(siteMap.Warnings, fmt.Sprintf("stopped after %d sitemaps", sitemapMaxChildren))
+			break
+		}
+		seen[uri] = true
+		siteMap.Sitemaps = append(siteMap.Sitemaps, uri)
+		data, truncated, err := fetchLimited(ctx, uri, sitemapTimeout, sitemapMaxBytes, opts)
+		if err != nil {
+			opts.logger().Printf("sitemap failed uri=%s error=%q", uri, err.Error())
+			siteMap.Warnings = append(siteMap.Warnings, "sitemap could not be read: "+uri)
+			continue
+		}
+		urls, children, complete := parseSitemap(data)
+		if truncated {
+			siteMap.Warnings = append(siteMap.Warnings, "sitemap exceeded the size or time limit and was read in part: "+uri)
+		} else if !complete {
+			siteMap.Warnings = append(siteMap.Warnings, "sitemap is malformed and was read in part: "+uri)
+		}
+		queue = append(queue, children...)
+		for i := 0; i < len(urls); i++ {
+			if len(siteMap.Urls) >= sitemapMaxUrls {
+				siteMap.Warnings = append(siteMap.Warnings, fmt.Sprintf("stopped after %d urls", sitemapMaxUrls))
+				return siteMap
+			}
+			siteMap.Urls = append(siteMap.Urls, urls[i])
+		}
+	}
+	return siteMap
+}
+
+func siteMapJson(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	root := scheme + "://" + strings.SplitN(normalizePath(vars["url"]), "/", 2)[0]
+	data := readSiteMap(r.Context(), root, fetchOptionsFromRequest(r))
+	writeJson(w, r, data)
+}
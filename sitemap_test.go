@@ -0,0 +1,64 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// largeSitemap returns a urlset listing n pages
+func largeSitemap(n int) string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?><urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "<url><loc>https://example.com/post-%d</loc></url>", i)
+	}
+	sb.WriteString("</urlset>")
+	return sb.String()
+}
+
+func TestParseSitemapKeepsEntriesBeforeACut(t *testing.T) {
+	data := largeSitemap(10)
+	urls, _, complete := parseSitemap([]byte(data[:len(data)/2]))
+	if complete || len(urls) < 1 || len(urls) >= 10 {
+		t.Errorf("expected some of the urls from an incomplete file, got %d (complete %v)", len(urls), complete)
+	}
+	urls, _, complete = parseSitemap([]byte(data))
+	if !complete || len(urls) != 10 || urls[9] != "https://example.com/post-9" {
+		t.Errorf("expected all 10 urls, got %d (complete %v)", len(urls), complete)
+	}
+}
+
+func TestOversizedSitemapIsReadInPart(t *testing.T) {
+	previous := sitemapMaxBytes
+	sitemapMaxBytes = 2000
+	defer func() { sitemapMaxBytes = previous }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprint(w, largeSitemap(1000))
+		case "/sitemap.xml.gz":
+			zipped := gzip.NewWriter(w)
+			fmt.Fprint(zipped, largeSitemap(1000))
+			zipped.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	data, truncated, err := fetchLimited(context.Background(), server.URL+"/sitemap.xml.gz", sitemapTimeout, sitemapMaxBytes, FetchOptions{})
+	if err != nil || !truncated || len(data) != sitemapMaxBytes {
+		t.Errorf("expected the gzipped sitemap cut at the decompressed limit, got %d bytes (truncated %v, error %v)", len(data), truncated, err)
+	}
+	siteMap := readSiteMap(context.Background(), server.URL, FetchOptions{})
+	if len(siteMap.Urls) < 1 || len(siteMap.Urls) >= 1000 {
+		t.Errorf("expected the urls before the cut, got %d", len(siteMap.Urls))
+	}
+	if len(siteMap.Warnings) != 1 || !strings.Contains(siteMap.Warnings[0], "read in part") {
+		t.Errorf("expected a warning for the cut sitemap, got %q", siteMap.Warnings)
+	}
+}
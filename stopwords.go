@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+const defaultStopwordLang = "en"
+
+// built-in stopword lists, each can be replaced with a comma separated STOPWORDS_<LANG> env var
+var builtinStopwords = map[string][]string{
+	"en": {"a", "about", "above", "after", "again", "against", "all", "am", "an", "and", "any", "are", "as", "at",
+		"be", "because", "been", "before", "being", "below", "between", "both", "but", "by", "can", "could",
+		"did", "do", "does", "doing", "down", "during", "each", "few", "for", "from", "further", "had", "has",
+		"have", "having", "he", "her", "here", "hers", "herself", "him", "himself", "his", "how", "i", "if", "in",
+		"into", "is", "it", "its", "itself", "just", "me", "more", "most", "my", "myself", "no", "nor", "not",
+		"now", "of", "off", "on", "once", "only", "or", "other", "our", "ours", "ourselves", "out", "over", "own",
+		"same", "she", "should", "so", "some", "such", "than", "that", "the", "their", "theirs", "them",
+		"themselves", "then", "there", "these", "they", "this", "those", "through", "to", "too", "under",
+		"until", "up", "very", "was", "we", "were", "what", "when", "where", "which", "while", "who", "whom",
+		"why", "will", "with", "would", "you", "your", "yours", "yourself", "yourselves"},
+	"fr": {"au", "aux", "avec", "ce", "ces", "dans", "de", "des", "du", "elle", "en", "et", "eux", "il", "je",
+		"la", "le", "les", "leur", "lui", "ma", "mais", "me", "mes", "moi", "mon", "ne", "nos", "notre", "nous",
+		"on", "ou", "où", "par", "pas", "pour", "qu", "que", "qui", "sa", "se", "ses", "son", "sur", "ta", "te",
+		"tes", "toi", "ton", "tu", "un", "une", "vos", "votre", "vous", "est", "sont", "été", "être", "a", "ont"},
+	"de": {"aber", "als", "am", "an", "auch", "auf", "aus", "bei", "bin", "bis", "das", "dass", "dem", "den",
+		"der", "des", "die", "doch", "du", "ein", "eine", "einem", "einen", "einer", "er", "es", "für", "hat",
+		"ich", "ihr", "im", "in", "ist", "ja", "mit", "nach", "nicht", "noch", "nur", "oder", "sein", "sie",
+		"sind", "so", "um", "und", "uns", "von", "vor", "war", "was", "wie", "wir", "wird", "zu", "zum", "zur"},
+	"es": {"a", "al", "como", "con", "de", "del", "el", "ella", "en", "es", "esta", "este", "fue", "ha", "la",
+		"las", "le", "lo", "los", "más", "me", "mi", "no", "nos", "o", "para", "pero", "por", "que", "se", "si",
+		"sin", "su", "sus", "también", "te", "tu", "un", "una", "uno", "y", "ya", "yo"},
+}
+
+// stopwordLang reduces a language tag such as en-GB to its primary subtag
+func stopwordLang(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if idx := strings.IndexAny(lang, "-_"); idx > 0 {
+		lang = lang[:idx]
+	}
+	if len(lang) < 1 {
+		return defaultStopwordLang
+	}
+	return lang
+}
+
+// stopwordsFor returns the stopword set for a language, empty for unknown languages
+func stopwordsFor(lang string) map[string]bool {
+	lang = stopwordLang(lang)
+	words := envList("STOPWORDS_"+strings.ToUpper(lang), builtinStopwords[lang])
+	set := make(map[string]bool, len(words))
+	for i := 0; i < len(words); i++ {
+		set[strings.ToLower(words[i])] = true
+	}
+	return set
+}
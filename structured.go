@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/headzoo/surf/browser"
+)
+
+type Recipe struct {
+	Name        string   `json:"name"`
+	Ingredients []string `json:"ingredients"`
+	Steps       []string `json:"steps"`
+	// times are ISO 8601 durations as published, e.g. PT1H30M
+	PrepTime  string `json:"prepTime,omitempty"`
+	CookTime  string `json:"cookTime,omitempty"`
+	TotalTime string `json:"totalTime,omitempty"`
+}
+
+type Product struct {
+	Name         string `json:"name"`
+	Price        string `json:"price,omitempty"`
+	Currency     string `json:"currency,omitempty"`
+	Availability string `json:"availability,omitempty"`
+}
+
+// structured data nested deeper than this is not searched
+const maxSchemaDepth = 8
+
+// readJsonLd decodes the JSON-LD blocks of the page, skipping any that are not valid JSON
+func readJsonLd(bow *browser.Browser) []interface{} {
+	blocks := []interface{}{}
+	bow.Find("script[type='application/ld+json']").Each(func(_ int, s *goquery.Selection) {
+		var block interface{}
+		if json.Unmarshal([]byte(s.Text()), &block) == nil {
+			blocks = append(blocks, block)
+		}
+	})
+	return blocks
+}
+
+// microdataNode converts a microdata item to the JSON-LD shape, so both are read the same way
+func microdataNode(item MicrodataItem) map[string]interface{} {
+	types := []interface{}{}
+	for i := 0; i < len(item.Type); i++ {
+		types = append(types, item.Type[i])
+	}
+	node := map[string]interface{}{"@type": types}
+	for name, values := range item.Properties {
+		converted := []interface{}{}
+		for i := 0; i < len(values); i++ {
+			if nested, ok := values[i].(MicrodataItem); ok {
+				converted = append(converted, microdataNode(nested))
+			} else {
+				converted = append(converted, values[i])
+			}
+		}
+		node[name] = converted
+	}
+	return node
+}
+
+// schemaTypes returns the types of a node without the schema.org prefix, e.g. Recipe
+func schemaTypes(node map[string]interface{}) []string {
+	types := []string{}
+	values := schemaValues(node["@type"])
+	for i := 0; i < len(values); i++ {
+		if name, ok := values[i].(string); ok {
+			types = append(types, name[strings.LastIndex(name, "/")+1:])
+		}
+	}
+	return types
+}
+
+func schemaValues(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return []interface{}{}
+	case []interface{}:
+		return v
+	}
+	return []interface{}{value}
+}
+
+// schemaText reads a value as text, taking the name or text of a nested node
+func schemaText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return removeSpaces(strings.TrimSpace(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		for _, key := range []string{"text", "name", "@value"} {
+			if text := schemaString(v, key); len(text) > 0 {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+func schemaStrings(node map[string]interface{}, key string) []string {
+	texts := []string{}
+	values := schemaValues(node[key])
+	for i := 0; i < len(values); i++ {
+		if text := schemaText(values[i]); len(text) > 0 {
+			texts = append(texts, text)
+		}
+	}
+	return texts
+}
+
+func schemaString(node map[string]interface{}, key string) string {
+	if texts := schemaStrings(node, key); len(texts) > 0 {
+		return texts[0]
+	}
+	return ""
+}
+
+// findSchemaNode searches the blocks, including @graph lists and nested nodes, for the first node of type name
+func findSchemaNode(value interface{}, name string, depth int) map[string]interface{} {
+	if depth > maxSchemaDepth {
+		return nil
+	}
+	switch v := value.(type) {
+	case []interface{}:
+		for i := 0; i < len(v); i++ {
+			if node := findSchemaNode(v[i], name, depth+1); node != nil {
+				return node
+			}
+		}
+	case map[string]interface{}:
+		if stringInList(schemaTypes(v), name) {
+			return v
+		}
+		for _, nested := range v {
+			if node := findSchemaNode(nested, name, depth+1); node != nil {
+				return node
+			}
+		}
+	}
+	return nil
+}
+
+// recipeSteps flattens recipeInstructions, which may be text, HowToStep nodes or HowToSection nodes listing steps
+func recipeSteps(value interface{}, depth int) []string {
+	steps := []string{}
+	values := schemaValues(value)
+	for i := 0; i < len(values) && depth <= maxSchemaDepth; i++ {
+		switch v := values[i].(type) {
+		case string:
+			lines := strings.Split(v, "\n")
+			for j := 0; j < len(lines); j++ {
+				if line := removeSpaces(strings.TrimSpace(lines[j])); len(line) > 0 {
+					steps = append(steps, line)
+				}
+			}
+		case map[string]interface{}:
+			if stringInList(schemaTypes(v), "HowToSection") {
+				steps = append(steps, recipeSteps(v["itemListElement"], depth+1)...)
+			} else if text := schemaText(v); len(text) > 0 {
+				steps = append(steps, text)
+			}
+		}
+	}
+	return steps
+}
+
+func readRecipe(node map[string]interface{}) *Recipe {
+	ingredients := schemaStrings(node, "recipeIngredient")
+	if len(ingredients) < 1 {
+		// the older property name is still common
+		ingredients = schemaStrings(node, "ingredients")
+	}
+	return &Recipe{
+		Name:        schemaString(node, "name"),
+		Ingredients: ingredients,
+		Steps:       recipeSteps(node["recipeInstructions"], 0),
+		PrepTime:    schemaString(node, "prepTime"),
+		CookTime:    schemaString(node, "cookTime"),
+		TotalTime:   schemaString(node, "totalTime"),
+	}
+}
+
+// readProduct takes the price of the first offer, or the lowest price of an AggregateOffer
+func readProduct(node map[string]interface{}) *Product {
+	product := &Product{Name: schemaString(node, "name")}
+	offers := schemaValues(node["offers"])
+	for i := 0; i < len(offers); i++ {
+		offer, ok := offers[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		product.Price = schemaString(offer, "price")
+		if len(product.Price) < 1 {
+			product.Price = schemaString(offer, "lowPrice")
+		}
+		product.Currency = schemaString(offer, "priceCurrency")
+		availability := schemaString(offer, "availability")
+		product.Availability = availability[strings.LastIndex(availability, "/")+1:]
+		break
+	}
+	return product
+}
+
+// readStructuredData returns the Recipe and Product declared in JSON-LD or microdata, JSON-LD first,
+// each nil when the page has none
+func readStructuredData(bow *browser.Browser, microdata []MicrodataItem) (*Recipe, *Product) {
+	sources := readJsonLd(bow)
+	for i := 0; i < len(microdata); i++ {
+		sources = append(sources, microdataNode(microdata[i]))
+	}
+	var recipe *Recipe
+	var product *Product
+	if node := findSchemaNode(sources, "Recipe", 0); node != nil {
+		recipe = readRecipe(node)
+	}
+	if node := findSchemaNode(sources, "Product", 0); node != nil {
+		product = readProduct(node)
+	}
+	return recipe, product
+}
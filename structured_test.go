@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const recipeJsonLd = `<html><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+	{"@type": "WebPage", "name": "Recipes"},
+	{"@type": ["Recipe"], "name": "Pancakes", "recipeIngredient": ["2 eggs", " 200g  flour "],
+	 "prepTime": "PT10M", "cookTime": "PT20M",
+	 "recipeInstructions": [
+		{"@type": "HowToSection", "name": "Batter", "itemListElement": [
+			{"@type": "HowToStep", "text": "Whisk the eggs."},
+			{"@type": "HowToStep", "text": "Stir in the flour."}]},
+		"Fry each side.\nServe warm."]}
+]}</script>
+<script type="application/ld+json">{not json</script>
+</head><body><p>A recipe page.</p></body></html>`
+
+func TestRecipeIsReadFromJsonLd(t *testing.T) {
+	recipe, product := readStructuredData(openHtml(t, recipeJsonLd), []MicrodataItem{})
+	if recipe == nil || product != nil {
+		t.Fatalf("expected a recipe and no product, got %v and %v", recipe, product)
+	}
+	if recipe.Name != "Pancakes" || recipe.PrepTime != "PT10M" || recipe.CookTime != "PT20M" {
+		t.Errorf("expected the name and times, got %+v", recipe)
+	}
+	if strings.Join(recipe.Ingredients, "|") != "2 eggs|200g flour" {
+		t.Errorf("expected trimmed ingredients, got %q", recipe.Ingredients)
+	}
+	if strings.Join(recipe.Steps, "|") != "Whisk the eggs.|Stir in the flour.|Fry each side.|Serve warm." {
+		t.Errorf("expected the section steps followed by the text lines, got %q", recipe.Steps)
+	}
+}
+
+const productJsonLd = `<html><head>
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Product", "name": "Kettle",
+	"offers": {"@type": "AggregateOffer", "lowPrice": 24.5, "priceCurrency": "EUR", "availability": "https://schema.org/InStock"}}</script>
+</head><body>
+<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">Toaster</span></div>
+</body></html>`
+
+func TestProductPrefersJsonLdOverMicrodata(t *testing.T) {
+	bow := openHtml(t, productJsonLd)
+	_, product := readStructuredData(bow, readMicrodata(bow))
+	if product == nil {
+		t.Fatalf("expected a product")
+	}
+	if product.Name != "Kettle" || product.Price != "24.5" || product.Currency != "EUR" || product.Availability != "InStock" {
+		t.Errorf("expected the JSON-LD product with its lowest price, got %+v", product)
+	}
+}
+
+const productMicrodata = `<html><body>
+<div itemscope itemtype="https://schema.org/Product"><span itemprop="name">Toaster</span>
+<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+<meta itemprop="price" content="39.99"><meta itemprop="priceCurrency" content="GBP">
+<link itemprop="availability" href="https://schema.org/OutOfStock"></div></div>
+</body></html>`
+
+func TestProductIsReadFromMicrodata(t *testing.T) {
+	bow := openHtml(t, productMicrodata)
+	_, product := readStructuredData(bow, readMicrodata(bow))
+	if product == nil || product.Name != "Toaster" || product.Price != "39.99" || product.Currency != "GBP" || product.Availability != "OutOfStock" {
+		t.Errorf("expected the microdata product and offer, got %+v", product)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// SelectorSuggestion pairs a candidate main content block with the heading that likely holds its title,
+// ready to be used as a scraper configuration
+type SelectorSuggestion struct {
+	ContentSelector string `json:"contentSelector"`
+	TitleSelector   string `json:"titleSelector"`
+	WordCount       int    `json:"wordCount"`
+}
+
+// number of content blocks with the most words suggested by discover
+const maxSelectorSuggestions = 5
+
+// headings in order of preference for the title of a block
+var titleHeadingTags = []string{"h1", "h2", "h3", "h4"}
+
+// titleSelectorWithin returns the selector of the highest ranking heading of block, scoped to contentSelector
+func titleSelectorWithin(block *goquery.Selection, contentSelector string) string {
+	for i := 0; i < len(titleHeadingTags); i++ {
+		heading := block.Find(titleHeadingTags[i]).First()
+		if heading.Length() > 0 {
+			set := ClassesIdSet{TagName: titleHeadingTags[i], Id: heading.AttrOr("id", ""), Classes: extractClasses(heading)}
+			return contentSelector + " " + set.ToPath()
+		}
+	}
+	return ""
+}
+
+// addSuggestion keeps the blocks with the most words, once per content selector
+func (ps *PageStats) addSuggestion(block *goquery.Selection, cData ClassesIdSet) {
+	contentSelector := cData.ToPath()
+	for i := 0; i < len(ps.Suggestions); i++ {
+		if ps.Suggestions[i].ContentSelector == contentSelector {
+			return
+		}
+	}
+	ps.Suggestions = append(ps.Suggestions, SelectorSuggestion{
+		ContentSelector: contentSelector,
+		TitleSelector:   titleSelectorWithin(block, contentSelector),
+		WordCount:       cData.WordCount,
+	})
+	sort.SliceStable(ps.Suggestions, func(a, b int) bool {
+		return ps.Suggestions[a].WordCount > ps.Suggestions[b].WordCount
+	})
+	if len(ps.Suggestions) > maxSelectorSuggestions {
+		ps.Suggestions = ps.Suggestions[:maxSelectorSuggestions]
+	}
+}
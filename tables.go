@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gorilla/mux"
+)
+
+type Table struct {
+	Caption string     `json:"caption"`
+	Headers []string   `json:"headers"`
+	Rows    [][]string `json:"rows"`
+}
+
+type PageTables struct {
+	Uri    string  `json:"uri"`
+	Exists bool    `json:"exists"`
+	Tables []Table `json:"tables"`
+}
+
+// a cell spanning rows is copied into the same column of the following rows
+type pendingCell struct {
+	text      string
+	remaining int
+}
+
+// guards against absurd span values in malformed markup
+const maxCellSpan = 100
+
+func cellSpan(cell *goquery.Selection, attr string) int {
+	span, err := strconv.Atoi(cell.AttrOr(attr, "1"))
+	if err != nil || span < 1 {
+		return 1
+	}
+	if span > maxCellSpan {
+		return maxCellSpan
+	}
+	return span
+}
+
+// tableRows returns the rows of a table in order, ignoring rows of nested tables
+func tableRows(table *goquery.Selection) []*goquery.Selection {
+	rows := []*goquery.Selection{}
+	table.Children().Each(func(_ int, child *goquery.Selection) {
+		switch goquery.NodeName(child) {
+		case "tr":
+			rows = append(rows, child)
+		case "thead", "tbody", "tfoot":
+			child.ChildrenFiltered("tr").Each(func(_ int, row *goquery.Selection) {
+				rows = append(rows, row)
+			})
+		}
+	})
+	return rows
+}
+
+// expandRow lays out the cells of a row on a grid, repeating colspan cells and
+// filling columns still covered by rowspan cells from earlier rows
+func expandRow(row *goquery.Selection, pending map[int]*pendingCell) []string {
+	values := []string{}
+	col := 0
+	fillPending := func() {
+		for {
+			cell, exists := pending[col]
+			if !exists {
+				return
+			}
+			values = append(values, cell.text)
+			cell.remaining--
+			if cell.remaining < 1 {
+				delete(pending, col)
+			}
+			col++
+		}
+	}
+	row.ChildrenFiltered("th,td").Each(func(_ int, cell *goquery.Selection) {
+		fillPending()
+		text := removeSpaces(cell.Text())
+		colspan := cellSpan(cell, "colspan")
+		rowspan := cellSpan(cell, "rowspan")
+		for i := 0; i < colspan; i++ {
+			values = append(values, text)
+			if rowspan > 1 {
+				pending[col] = &pendingCell{text: text, remaining: rowspan - 1}
+			}
+			col++
+		}
+	})
+	fillPending()
+	return values
+}
+
+func isHeaderRow(row *goquery.Selection) bool {
+	cells := row.ChildrenFiltered("th,td")
+	return cells.Length() > 0 && cells.Length() == row.ChildrenFiltered("th").Length()
+}
+
+func parseTable(table *goquery.Selection) Table {
+	data := Table{Caption: removeSpaces(table.ChildrenFiltered("caption").Text()), Headers: []string{}, Rows: [][]string{}}
+	pending := map[int]*pendingCell{}
+	rows := tableRows(table)
+	for i := 0; i < len(rows); i++ {
+		values := expandRow(rows[i], pending)
+		inHead := goquery.NodeName(rows[i].Parent()) == "thead"
+		if len(data.Headers) < 1 && len(data.Rows) < 1 && (inHead || isHeaderRow(rows[i])) {
+			data.Headers = values
+		} else if len(values) > 0 {
+			data.Rows = append(data.Rows, values)
+		}
+	}
+	return data
+}
+
+func readLiveTables(ctx context.Context, uri string, opts FetchOptions) PageTables {
+	bow, err := fetchPage(ctx, uri, opts)
+	exists := err == nil
+	tables := []Table{}
+	if exists {
+		bow.Find("table").Each(func(_ int, s *goquery.Selection) {
+			tables = append(tables, parseTable(s))
+		})
+	}
+	return PageTables{Uri: uri, Exists: exists, Tables: tables}
+}
+
+func tablesPage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	scheme, valid := requestScheme(w, vars)
+	if !valid {
+		return
+	}
+	url := scheme + "://" + normalizePath(vars["url"])
+	opts := fetchOptionsFromRequest(r)
+	var data PageTables
+	if !readEndpointCache(r.Context(), "tables", url, &data, opts) {
+		data = readLiveTables(r.Context(), url, opts)
+		writeEndpointCache(r.Context(), "tables", url, data, opts)
+	}
+	writeJson(w, r, data)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// elements whose whitespace is meaningful, such as the indentation of code samples
+var preformattedTags = map[string]bool{"pre": true, "code": true}
+
+var whitespaceRgx = regexp.MustCompile(`\s+`)
+
+// normalizedText returns the text of selection with runs of whitespace collapsed to single spaces,
+// except inside <pre> and <code>, which keep their spacing and line breaks as written
+func normalizedText(selection *goquery.Selection) string {
+	var sb strings.Builder
+	for i := 0; i < len(selection.Nodes); i++ {
+		writeNodeText(&sb, selection.Nodes[i], false)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func writeNodeText(sb *strings.Builder, node *html.Node, preformatted bool) {
+	switch node.Type {
+	case html.TextNode:
+		if preformatted {
+			sb.WriteString(node.Data)
+			return
+		}
+		text := whitespaceRgx.ReplaceAllString(node.Data, " ")
+		if strings.HasPrefix(text, " ") && (sb.Len() < 1 || strings.HasSuffix(sb.String(), " ") || strings.HasSuffix(sb.String(), "\n")) {
+			text = text[1:]
+		}
+		sb.WriteString(text)
+		return
+	case html.ElementNode:
+		if node.Data == "script" || node.Data == "style" {
+			return
+		}
+	}
+	// a code block starts and ends on its own line so its first line keeps its indentation
+	block := node.Type == html.ElementNode && node.Data == "pre" && !preformatted
+	if block && sb.Len() > 0 {
+		sb.WriteString("\n")
+	}
+	inner := preformatted || (node.Type == html.ElementNode && preformattedTags[node.Data])
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		writeNodeText(sb, child, inner)
+	}
+	if block {
+		sb.WriteString("\n")
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const indentedCode = `<html><body>
+<h1>Loops   in
+  Go</h1>
+<p>Count   to three:</p><pre><code>for i := 0; i < 3; i++ {
+    fmt.Println(i)
+}</code></pre><p>Then <code>go  run</code> it.</p>
+<script>var ignored = 1;</script>
+</body></html>`
+
+func TestNormalizedTextKeepsIndentedCodeBlocks(t *testing.T) {
+	text := readBodyText(openHtml(t, indentedCode))
+	expected := "Loops in Go Count to three:\nfor i := 0; i < 3; i++ {\n    fmt.Println(i)\n}\nThen go  run it."
+	if text != expected {
+		t.Errorf("expected %q, got %q", expected, text)
+	}
+	if strings.Contains(text, "ignored") {
+		t.Errorf("expected script text to be left out")
+	}
+	if words := len(strings.Fields(text)); words != 21 {
+		t.Errorf("expected the code to count as words like any text, got %d", words)
+	}
+}
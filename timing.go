@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+type PhaseTiming struct {
+	Name string  `json:"name"`
+	Ms   float64 `json:"ms"`
+}
+
+// phaseTimer records the duration of consecutive phases of a crawl
+type phaseTimer struct {
+	last   time.Time
+	phases []PhaseTiming
+}
+
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{last: time.Now()}
+}
+
+// mark closes the current phase under name and starts the next one
+func (pt *phaseTimer) mark(name string) {
+	now := time.Now()
+	ms := float64(now.Sub(pt.last).Microseconds()) / 1000
+	pt.phases = append(pt.phases, PhaseTiming{Name: name, Ms: math.Round(ms*100) / 100})
+	pt.last = now
+}
+
+// TimingSummary groups the phases of a crawl. The document is built as the response is read, so
+// fetchMs covers the request and the HTML parse, as well as any render; parseMs covers the reads of the
+// whole page such as its text, language, media and structured data; extractMs covers its articles and links
+type TimingSummary struct {
+	FetchMs   float64 `json:"fetchMs"`
+	ParseMs   float64 `json:"parseMs"`
+	ExtractMs float64 `json:"extractMs"`
+	TotalMs   float64 `json:"totalMs"`
+}
+
+func summarizeTimings(timings []PhaseTiming) *TimingSummary {
+	summary := &TimingSummary{}
+	for i := 0; i < len(timings); i++ {
+		switch timings[i].Name {
+		case "fetch", "render":
+			summary.FetchMs += timings[i].Ms
+		case "parse":
+			summary.ParseMs += timings[i].Ms
+		default:
+			summary.ExtractMs += timings[i].Ms
+		}
+		summary.TotalMs += timings[i].Ms
+	}
+	summary.FetchMs = math.Round(summary.FetchMs*100) / 100
+	summary.ParseMs = math.Round(summary.ParseMs*100) / 100
+	summary.ExtractMs = math.Round(summary.ExtractMs*100) / 100
+	summary.TotalMs = math.Round(summary.TotalMs*100) / 100
+	return summary
+}
+
+// serverTimingHeader formats timings for the Server-Timing response header
+func serverTimingHeader(timings []PhaseTiming) string {
+	parts := []string{}
+	for i := 0; i < len(timings); i++ {
+		parts = append(parts, fmt.Sprintf("%s;dur=%.2f", timings[i].Name, timings[i].Ms))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTimingsIncludeTheirSummary(t *testing.T) {
+	page := Page{Timings: []PhaseTiming{{Name: "fetch", Ms: 30}, {Name: "render", Ms: 10}, {Name: "parse", Ms: 5}, {Name: "articles", Ms: 2.5}, {Name: "links", Ms: 1}}}
+	w := httptest.NewRecorder()
+	writeBlogPage(w, httptest.NewRequest(http.MethodGet, "/?timings=true", nil), page, FetchOptions{})
+	var body Page
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if len(body.Timings) != 5 || body.Timing == nil {
+		t.Fatalf("expected the phases and their summary, got %s", w.Body.String())
+	}
+	if *body.Timing != (TimingSummary{FetchMs: 40, ParseMs: 5, ExtractMs: 3.5, TotalMs: 48.5}) {
+		t.Errorf("unexpected summary %+v", *body.Timing)
+	}
+	if len(w.Header().Get("Server-Timing")) < 1 {
+		t.Errorf("expected a Server-Timing header")
+	}
+	w = httptest.NewRecorder()
+	writeBlogPage(w, httptest.NewRequest(http.MethodGet, "/?timing=true", nil), page, FetchOptions{})
+	body = Page{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if body.Timing == nil || body.Timing.TotalMs != 48.5 {
+		t.Errorf("expected timing to be accepted as an alias of timings, got %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	writeBlogPage(w, httptest.NewRequest(http.MethodGet, "/", nil), page, FetchOptions{})
+	body = Page{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if len(body.Timings) != 0 || body.Timing != nil {
+		t.Errorf("expected no timings unless asked, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/headzoo/surf/browser"
+)
+
+// sources tried in turn for pages without a <title>, overridable with TITLE_FALLBACKS
+var titleFallbacks = envList("TITLE_FALLBACKS", []string{"og:title", "h1", "url"})
+
+// humanizeSlug turns the last path segment of uri into words, e.g. /blog/my-first_post.html to My first post
+func humanizeSlug(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	segment := path.Base(strings.TrimRight(parsed.Path, "/"))
+	if segment == "." || segment == "/" {
+		return parsed.Hostname()
+	}
+	segment = strings.TrimSuffix(segment, path.Ext(segment))
+	words := strings.Fields(strings.NewReplacer("-", " ", "_", " ", "+", " ").Replace(segment))
+	if len(words) < 1 {
+		return parsed.Hostname()
+	}
+	title := []rune(strings.Join(words, " "))
+	title[0] = unicode.ToUpper(title[0])
+	return string(title)
+}
+
+func titleFromSource(bow *browser.Browser, uri string, source string) string {
+	switch source {
+	case "og:title":
+		return removeSpaces(strings.TrimSpace(bow.Find("meta[property='og:title']").First().AttrOr("content", "")))
+	case "h1":
+		return removeSpaces(strings.TrimSpace(bow.Find("h1").First().Text()))
+	case "url":
+		return humanizeSlug(uri)
+	}
+	return ""
+}
+
+// pageTitle returns the <title> of the page, or the first non-empty fallback, with the source it came from
+func pageTitle(bow *browser.Browser, uri string) (string, string) {
+	if title := strings.TrimSpace(bow.Title()); len(title) > 0 {
+		return bow.Title(), "title"
+	}
+	for i := 0; i < len(titleFallbacks); i++ {
+		if title := titleFromSource(bow, uri, titleFallbacks[i]); len(title) > 0 {
+			return title, titleFallbacks[i]
+		}
+	}
+	return "", ""
+}
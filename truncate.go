@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// truncateHtml cuts content to at most maxBytes, backing up to the start of a tag the cut falls in
+// so no tag is left half written, and otherwise to a character boundary
+func truncateHtml(content string, maxBytes int) (string, bool) {
+	if maxBytes < 1 || len(content) <= maxBytes {
+		return content, false
+	}
+	cut := content[:maxBytes]
+	tagStart := strings.LastIndex(cut, "<")
+	tagEnd := strings.LastIndex(cut, ">")
+	if tagStart > tagEnd {
+		cut = cut[:tagStart]
+	}
+	for len(cut) > 0 && !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return cut, true
+}
+
+const truncationEllipsis = "…"
+
+// htmlEntityEnd returns the offset past a character reference such as &amp; starting at offset,
+// or 0 when the ampersand starts none
+func htmlEntityEnd(content string, offset int) int {
+	end := strings.IndexByte(content[offset:], ';')
+	if end < 2 || end > 10 || strings.ContainsAny(content[offset+1:offset+end], " <&\n") {
+		return 0
+	}
+	return offset + end + 1
+}
+
+// truncateHtmlChars cuts content after maxChars characters of text, markup not counting
+// and a character reference counting as one, and marks the cut with an ellipsis
+func truncateHtmlChars(content string, maxChars int) (string, bool) {
+	if maxChars < 1 {
+		return content, false
+	}
+	chars := 0
+	inTag := false
+	for offset := 0; offset < len(content); {
+		r, size := utf8.DecodeRuneInString(content[offset:])
+		switch {
+		case inTag:
+			inTag = r != '>'
+		case r == '<':
+			inTag = true
+		default:
+			if chars == maxChars {
+				return content[:offset] + truncationEllipsis, true
+			}
+			chars++
+			if r == '&' {
+				if end := htmlEntityEnd(content, offset); end > 0 {
+					size = end - offset
+				}
+			}
+		}
+		offset += size
+	}
+	return content, false
+}
+
+// truncateArticles applies the content size and length caps to each article
+func truncateArticles(articles []Article, maxBytes int, maxChars int) {
+	for i := 0; i < len(articles); i++ {
+		content, truncatedBytes := truncateHtml(articles[i].Content, maxBytes)
+		content, truncatedChars := truncateHtmlChars(content, maxChars)
+		articles[i].Content = content
+		articles[i].ContentTruncated = truncatedBytes || truncatedChars
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTruncateHtmlBacksUpOutOfAHalfWrittenTag(t *testing.T) {
+	content := `<p>Hello</p><p>World wide</p>`
+	if cut, truncated := truncateHtml(content, 20); !truncated || cut != `<p>Hello</p><p>World` {
+		t.Errorf("expected the cut within the text, got %q", cut)
+	}
+	if cut, _ := truncateHtml(content, 14); cut != `<p>Hello</p>` {
+		t.Errorf("expected a half written tag to be dropped, got %q", cut)
+	}
+	if cut, _ := truncateHtml("héllo", 2); cut != "h" {
+		t.Errorf("expected a cut on a character boundary, got %q", cut)
+	}
+	if cut, truncated := truncateHtml(content, len(content)); truncated || cut != content {
+		t.Errorf("expected content within the cap to be kept, got %q", cut)
+	}
+}
+
+func TestTruncateHtmlKeepsTheTextOfALongNode(t *testing.T) {
+	content := "<div><p>" + strings.Repeat("é", 5000) + "</p></div>"
+	cut, truncated := truncateHtml(content, 1001)
+	if !truncated || cut != "<div><p>"+strings.Repeat("é", 496) {
+		t.Errorf("expected the text up to the last whole character, got %d bytes", len(cut))
+	}
+}
+
+func TestTruncateHtmlCharsCountsTextOnly(t *testing.T) {
+	content := `<p class="lead">Héllo <a href="/a-long-link">wörld</a> &amp; more</p>`
+	if cut, truncated := truncateHtmlChars(content, 20); truncated || cut != content {
+		t.Errorf("expected 18 characters of text to be within the cap, got %q", cut)
+	}
+	if cut, truncated := truncateHtmlChars(content, 9); !truncated || cut != `<p class="lead">Héllo <a href="/a-long-link">wör…` {
+		t.Errorf("expected 9 characters of text, got %q", cut)
+	}
+	if cut, _ := truncateHtmlChars(content, 13); cut != `<p class="lead">Héllo <a href="/a-long-link">wörld</a> &amp;…` {
+		t.Errorf("expected a character reference to count as one character, got %q", cut)
+	}
+	if cut, _ := truncateHtmlChars("AT&T rocks", 4); cut != "AT&T…" {
+		t.Errorf("expected a bare ampersand to count as one character, got %q", cut)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/headzoo/surf/browser"
+)
+
+// print versions are usually flagged in the path or query, e.g. /print/123 or ?print=1
+var printUriRgx = regexp.MustCompile(`(?i)(/print(/|$|\.)|[?&](print|format=print|output=print)(=|&|$))`)
+
+func resolveLinkHref(bow *browser.Browser, selector string) string {
+	href := strings.TrimSpace(bow.Find(selector).First().AttrOr("href", ""))
+	if len(href) < 1 {
+		return ""
+	}
+	uri, err := bow.ResolveStringUrl(href)
+	if err != nil {
+		return ""
+	}
+	return uri
+}
+
+// isAmpDocument checks the <html amp> or <html ⚡> markers required by the AMP spec
+func isAmpDocument(bow *browser.Browser) bool {
+	html := bow.Find("html")
+	_, amp := html.Attr("amp")
+	_, bolt := html.Attr("⚡")
+	return amp || bolt
+}
+
+// pageVariant returns amp or print when the fetched page is an alternate rendering of the canonical
+func pageVariant(bow *browser.Browser) string {
+	if isAmpDocument(bow) {
+		return "amp"
+	}
+	if bow.Url() != nil && printUriRgx.MatchString(bow.Url().RequestURI()) {
+		return "print"
+	}
+	return ""
+}
+
+// readVariants sets the canonical and AMP links on the page and flags AMP or print variants,
+// for which clients should prefer the canonical uri
+func readVariants(bow *browser.Browser, page *Page) {
+	page.Canonical = resolveLinkHref(bow, "link[rel='canonical']")
+	page.AmpUri = resolveLinkHref(bow, "link[rel='amphtml']")
+	page.Variant = pageVariant(bow)
+	if page.Variant == "amp" && len(page.AmpUri) < 1 && bow.Url() != nil {
+		page.AmpUri = bow.Url().String()
+	}
+}
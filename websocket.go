@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+type batchRequest struct {
+	Urls []string `json:"urls"`
+	// Headers are forwarded upstream for every url as with ?header=, under the same whitelist
+	Headers map[string]string `json:"headers"`
+}
+
+// fetchOptions adds the headers of the batch to those of the query, which they override
+func (req batchRequest) fetchOptions(opts FetchOptions) FetchOptions {
+	headers := http.Header{}
+	for name, values := range opts.Headers {
+		headers[name] = values
+	}
+	for name, value := range req.Headers {
+		addForwardHeader(headers, name, value)
+	}
+	opts.Headers = headers
+	return opts
+}
+
+type batchMessage struct {
+	Type  string `json:"type"`
+	Page  *Page  `json:"page,omitempty"`
+	Count int    `json:"count,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// streamBatch reads one {"urls": [...]} message, then sends a page message as each page
+// completes and a final done message. Remaining work is cancelled when the client disconnects
+func streamBatch(ws *websocket.Conn, opts FetchOptions) {
+	defer ws.Close()
+	var req batchRequest
+	if err := websocket.JSON.Receive(ws, &req); err != nil {
+		websocket.JSON.Send(ws, batchMessage{Type: "error", Error: "expected a JSON message with a urls list"})
+		return
+	}
+	if len(req.Urls) > batchMaxUrls {
+		websocket.JSON.Send(ws, batchMessage{Type: "error", Error: "too many urls"})
+		return
+	}
+	opts = req.fetchOptions(opts)
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+	// the client sends nothing more, so a failed read means it has gone away
+	go func() {
+		var ignored []byte
+		for websocket.Message.Receive(ws, &ignored) == nil {
+		}
+		cancel()
+	}()
+	count := 0
+	runBatch(ctx, uniqueUrls(req.Urls), opts, func(_ string, page Page) {
+		page = batchPage(page, opts.Extract)
+		if ctx.Err() != nil {
+			return
+		}
+		if err := websocket.JSON.Send(ws, batchMessage{Type: "page", Page: &page}); err != nil {
+			cancel()
+			return
+		}
+		count++
+	})
+	if ctx.Err() == nil {
+		websocket.JSON.Send(ws, batchMessage{Type: "done", Count: count})
+	}
+}
+
+// batchSocket upgrades to a WebSocket, any origin is accepted as with the other read-only routes
+func batchSocket(w http.ResponseWriter, r *http.Request) {
+	if !validDateRange(w, r) {
+		return
+	}
+	opts := fetchOptionsFromRequest(r)
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		streamBatch(ws, opts)
+	}}
+	server.ServeHTTP(w, r)
+}